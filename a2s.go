@@ -0,0 +1,570 @@
+package main
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"time"
+)
+
+// Steam server query (A2S) protocol implementation.
+// See https://developer.valvesoftware.com/wiki/Server_queries
+
+const (
+	a2sPacketSingle = -1
+	a2sPacketSplit  = -2
+
+	a2sInfoRequest   = 0x54
+	a2sPlayerRequest = 0x55
+	a2sRulesRequest  = 0x56
+
+	a2sInfoResponse      = 0x49
+	a2sPlayerResponse    = 0x44
+	a2sRulesResponse     = 0x45
+	a2sChallengeResponse = 0x41
+
+	a2sMaxPacketSize = 1400
+	a2sMaxSplits     = 64
+	a2sQueryTimeout  = time.Second * 5
+)
+
+var (
+	errA2SHeader      = errors.New("invalid a2s packet header")
+	errA2SResponse    = errors.New("unexpected a2s response type")
+	errA2SShortPacket = errors.New("a2s packet too short")
+	errA2SSplit       = errors.New("invalid a2s split packet")
+	errA2SChecksum    = errors.New("a2s decompressed payload checksum mismatch")
+)
+
+type a2sInfo struct {
+	Protocol    uint8
+	Name        string
+	Map         string
+	Folder      string
+	Game        string
+	AppID       uint16
+	Players     uint8
+	MaxPlayers  uint8
+	Bots        uint8
+	ServerType  string
+	Environment string
+	Visibility  bool
+	VAC         bool
+	Version     string
+	Port        uint16
+	SteamID     uint64
+	Keywords    string
+	GameID      uint64
+}
+
+type a2sPlayer struct {
+	Index    uint8
+	Name     string
+	Score    int32
+	Duration time.Duration
+}
+
+// a2sClient performs queries against a single server over UDP.
+type a2sClient struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func newA2SClient(ctx context.Context, addr string) (*a2sClient, error) {
+	dialer := net.Dialer{}
+
+	conn, errConn := dialer.DialContext(ctx, "udp", addr)
+	if errConn != nil {
+		return nil, errors.Join(errConn, errDial)
+	}
+
+	return &a2sClient{conn: conn, timeout: a2sQueryTimeout}, nil
+}
+
+func (c *a2sClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *a2sClient) info(ctx context.Context) (*a2sInfo, error) {
+	request := append([]byte{0xff, 0xff, 0xff, 0xff, a2sInfoRequest}, []byte("Source Engine Query\x00")...)
+
+	payload, errQuery := c.query(ctx, request, a2sInfoResponse, func(challenge []byte) []byte {
+		return append(append([]byte{}, request...), challenge...)
+	})
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	info, errParseInfo := parseA2SInfo(payload)
+	if errParseInfo != nil {
+		return nil, errors.Join(errParseInfo, errParse)
+	}
+
+	return info, nil
+}
+
+func (c *a2sClient) players(ctx context.Context) ([]a2sPlayer, error) {
+	request := func(challenge []byte) []byte {
+		return append([]byte{0xff, 0xff, 0xff, 0xff, a2sPlayerRequest}, challenge...)
+	}
+
+	payload, errQuery := c.query(ctx, request([]byte{0xff, 0xff, 0xff, 0xff}), a2sPlayerResponse, request)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	players, errParsePlayers := parseA2SPlayers(payload)
+	if errParsePlayers != nil {
+		return nil, errors.Join(errParsePlayers, errParse)
+	}
+
+	return players, nil
+}
+
+func (c *a2sClient) rules(ctx context.Context) (map[string]string, error) {
+	request := func(challenge []byte) []byte {
+		return append([]byte{0xff, 0xff, 0xff, 0xff, a2sRulesRequest}, challenge...)
+	}
+
+	payload, errQuery := c.query(ctx, request([]byte{0xff, 0xff, 0xff, 0xff}), a2sRulesResponse, request)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	return parseA2SRules(payload)
+}
+
+// query sends the request and returns the response payload, with the header and response type
+// stripped. If the server replies with a challenge, the request is rebuilt using withChallenge
+// and sent again.
+func (c *a2sClient) query(ctx context.Context, request []byte, expected byte, withChallenge func([]byte) []byte) ([]byte, error) {
+	deadline := time.Now().Add(c.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	if errDeadline := c.conn.SetDeadline(deadline); errDeadline != nil {
+		return nil, errors.Join(errDeadline, errDial)
+	}
+
+	// Allow a couple of challenge round trips, some servers issue a new challenge for the
+	// first challenged request.
+	for range 3 {
+		if _, errWrite := c.conn.Write(request); errWrite != nil {
+			return nil, errors.Join(errWrite, errDial)
+		}
+
+		payload, errRead := c.read()
+		if errRead != nil {
+			return nil, errRead
+		}
+
+		if len(payload) < 1 {
+			return nil, errors.Join(errA2SShortPacket, errParse)
+		}
+
+		switch payload[0] {
+		case expected:
+			return payload[1:], nil
+		case a2sChallengeResponse:
+			if len(payload) < 5 {
+				return nil, errors.Join(errA2SShortPacket, errParse)
+			}
+
+			request = withChallenge(payload[1:5])
+		default:
+			return nil, errors.Join(fmt.Errorf("%w: 0x%02x", errA2SResponse, payload[0]), errParse)
+		}
+	}
+
+	return nil, errors.Join(fmt.Errorf("%w: too many challenges", errA2SResponse), errParse)
+}
+
+// read returns the next complete response payload, reassembling split packets as required.
+func (c *a2sClient) read() ([]byte, error) {
+	buf := make([]byte, a2sMaxPacketSize*2)
+
+	var splits *a2sSplitAssembler
+
+	for {
+		size, errRead := c.conn.Read(buf)
+		if errRead != nil {
+			return nil, errors.Join(errRead, errExec)
+		}
+
+		packet := buf[:size]
+		if len(packet) < 4 {
+			return nil, errors.Join(errA2SShortPacket, errParse)
+		}
+
+		switch int32(binary.LittleEndian.Uint32(packet)) { //nolint:gosec
+		case a2sPacketSingle:
+			return bytes.Clone(packet[4:]), nil
+		case a2sPacketSplit:
+			if splits == nil {
+				splits = &a2sSplitAssembler{}
+			}
+
+			payload, complete, errSplit := splits.add(packet[4:])
+			if errSplit != nil {
+				return nil, errors.Join(errSplit, errParse)
+			}
+
+			if complete {
+				return payload, nil
+			}
+		default:
+			return nil, errors.Join(errA2SHeader, errParse)
+		}
+	}
+}
+
+// a2sSplitAssembler collects the fragments of a split response.
+type a2sSplitAssembler struct {
+	id         uint32
+	total      int
+	compressed bool
+	parts      map[int][]byte
+}
+
+// add consumes a single split packet (with the leading -2 header removed) and returns the
+// reassembled payload once every part has been received.
+func (a *a2sSplitAssembler) add(packet []byte) ([]byte, bool, error) {
+	reader := newA2SReader(packet)
+
+	id := reader.uint32()
+	total := int(reader.byte())
+	number := int(reader.byte())
+	_ = reader.uint16() // max packet size
+
+	if reader.err != nil {
+		return nil, false, reader.err
+	}
+
+	if total == 0 || total > a2sMaxSplits || number >= total {
+		return nil, false, errA2SSplit
+	}
+
+	if a.parts == nil {
+		a.id = id
+		a.total = total
+		a.compressed = id&0x80000000 != 0
+		a.parts = map[int][]byte{}
+	} else if a.id != id || a.total != total {
+		return nil, false, errA2SSplit
+	}
+
+	a.parts[number] = bytes.Clone(reader.remaining())
+
+	if len(a.parts) < a.total {
+		return nil, false, nil
+	}
+
+	numbers := make([]int, 0, len(a.parts))
+	for num := range a.parts {
+		numbers = append(numbers, num)
+	}
+
+	sort.Ints(numbers)
+
+	var joined []byte
+	for _, num := range numbers {
+		joined = append(joined, a.parts[num]...)
+	}
+
+	if a.compressed {
+		decompressed, errDecompress := a2sDecompress(joined)
+		if errDecompress != nil {
+			return nil, false, errDecompress
+		}
+
+		joined = decompressed
+	}
+
+	if len(joined) < 4 || int32(binary.LittleEndian.Uint32(joined)) != a2sPacketSingle { //nolint:gosec
+		return nil, false, errA2SHeader
+	}
+
+	return joined[4:], true, nil
+}
+
+// a2sDecompress handles the bzip2 compressed payloads sent by some older engines. The first part
+// is prefixed with the decompressed size and a crc32 of the decompressed data.
+func a2sDecompress(payload []byte) ([]byte, error) {
+	reader := newA2SReader(payload)
+	size := reader.uint32()
+	checksum := reader.uint32()
+
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	if size > a2sMaxPacketSize*a2sMaxSplits {
+		return nil, errA2SSplit
+	}
+
+	decompressed, errRead := io.ReadAll(io.LimitReader(bzip2.NewReader(bytes.NewReader(reader.remaining())), int64(size)))
+	if errRead != nil {
+		return nil, errors.Join(errRead, errA2SSplit)
+	}
+
+	if crc32.ChecksumIEEE(decompressed) != checksum {
+		return nil, errA2SChecksum
+	}
+
+	return decompressed, nil
+}
+
+func parseA2SInfo(payload []byte) (*a2sInfo, error) {
+	const (
+		edfPort      = 0x80
+		edfSteamID   = 0x10
+		edfSourceTV  = 0x40
+		edfKeywords  = 0x20
+		edfGameID    = 0x01
+		theShipAppID = 2400
+	)
+
+	reader := newA2SReader(payload)
+	info := a2sInfo{
+		Protocol:    reader.byte(),
+		Name:        reader.string(),
+		Map:         reader.string(),
+		Folder:      reader.string(),
+		Game:        reader.string(),
+		AppID:       reader.uint16(),
+		Players:     reader.byte(),
+		MaxPlayers:  reader.byte(),
+		Bots:        reader.byte(),
+		ServerType:  a2sServerType(reader.byte()),
+		Environment: a2sEnvironment(reader.byte()),
+		Visibility:  reader.byte() == 1,
+		VAC:         reader.byte() == 1,
+	}
+
+	if info.AppID == theShipAppID {
+		_ = reader.byte() // mode
+		_ = reader.byte() // witnesses
+		_ = reader.byte() // duration
+	}
+
+	info.Version = reader.string()
+
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	if reader.len() == 0 {
+		return &info, nil
+	}
+
+	edf := reader.byte()
+
+	if edf&edfPort != 0 {
+		info.Port = reader.uint16()
+	}
+
+	if edf&edfSteamID != 0 {
+		info.SteamID = reader.uint64()
+	}
+
+	if edf&edfSourceTV != 0 {
+		_ = reader.uint16()
+		_ = reader.string()
+	}
+
+	if edf&edfKeywords != 0 {
+		info.Keywords = reader.string()
+	}
+
+	if edf&edfGameID != 0 {
+		info.GameID = reader.uint64()
+	}
+
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	return &info, nil
+}
+
+func parseA2SPlayers(payload []byte) ([]a2sPlayer, error) {
+	reader := newA2SReader(payload)
+	count := int(reader.byte())
+	players := make([]a2sPlayer, 0, count)
+
+	// The count byte overflows on servers with more than 255 players, so read until the data
+	// runs out rather than trusting it.
+	for reader.len() > 0 && reader.err == nil {
+		player := a2sPlayer{
+			Index: reader.byte(),
+			Name:  reader.string(),
+			Score: int32(reader.uint32()), //nolint:gosec
+		}
+		duration := reader.float32()
+
+		if reader.err != nil {
+			break
+		}
+
+		player.Duration = time.Duration(float64(duration) * float64(time.Second))
+		players = append(players, player)
+	}
+
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	return players, nil
+}
+
+func parseA2SRules(payload []byte) (map[string]string, error) {
+	reader := newA2SReader(payload)
+	count := int(reader.uint16())
+	rules := make(map[string]string, count)
+
+	for range count {
+		name := reader.string()
+		value := reader.string()
+
+		if reader.err != nil {
+			// Rule responses are frequently truncated by the server, return what was read.
+			break
+		}
+
+		rules[name] = value
+	}
+
+	return rules, nil
+}
+
+func a2sServerType(value byte) string {
+	switch value {
+	case 'd', 'D':
+		return "dedicated"
+	case 'l', 'L':
+		return "listen"
+	case 'p', 'P':
+		return "proxy"
+	default:
+		return "unknown"
+	}
+}
+
+func a2sEnvironment(value byte) string {
+	switch value {
+	case 'l', 'L':
+		return "linux"
+	case 'w', 'W':
+		return "windows"
+	case 'm', 'o':
+		return "mac"
+	default:
+		return "unknown"
+	}
+}
+
+// a2sReader reads little endian protocol values, recording the first error encountered so callers
+// only need to check once after reading a group of fields.
+type a2sReader struct {
+	data []byte
+	err  error
+}
+
+func newA2SReader(data []byte) *a2sReader {
+	return &a2sReader{data: data}
+}
+
+func (r *a2sReader) len() int {
+	return len(r.data)
+}
+
+func (r *a2sReader) remaining() []byte {
+	return r.data
+}
+
+func (r *a2sReader) take(size int) []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	if len(r.data) < size {
+		r.err = errA2SShortPacket
+		r.data = nil
+
+		return nil
+	}
+
+	value := r.data[:size]
+	r.data = r.data[size:]
+
+	return value
+}
+
+func (r *a2sReader) byte() byte {
+	value := r.take(1)
+	if value == nil {
+		return 0
+	}
+
+	return value[0]
+}
+
+func (r *a2sReader) uint16() uint16 {
+	value := r.take(2)
+	if value == nil {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint16(value)
+}
+
+func (r *a2sReader) uint32() uint32 {
+	value := r.take(4)
+	if value == nil {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint32(value)
+}
+
+func (r *a2sReader) uint64() uint64 {
+	value := r.take(8)
+	if value == nil {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint64(value)
+}
+
+func (r *a2sReader) float32() float32 {
+	return math.Float32frombits(r.uint32())
+}
+
+func (r *a2sReader) string() string {
+	if r.err != nil {
+		return ""
+	}
+
+	idx := bytes.IndexByte(r.data, 0)
+	if idx < 0 {
+		r.err = errA2SShortPacket
+		r.data = nil
+
+		return ""
+	}
+
+	// Names are truncated by the engine at a byte limit, which can split a multibyte character.
+	value := labelValue(string(r.data[:idx]))
+	r.data = r.data[idx+1:]
+
+	return value
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// a2sSnapshot holds the most recent query results for a single a2s target.
+type a2sSnapshot struct {
+	info        *a2sInfo
+	players     []a2sPlayer
+	rules       map[string]string
+	lastSuccess time.Time
+	errClass    string
+}
+
+func (s a2sSnapshot) online() bool {
+	return s.errClass == "" && s.info != nil
+}
+
+// a2sCollector polls targets configured with the a2s protocol using the steam server query protocol. This
+// works without a rcon password, so it can be used against servers we don't administer.
+type a2sCollector struct {
+	mu        sync.RWMutex
+	config    *config
+	snapshots map[string]a2sSnapshot
+	loops     *pollGroup
+}
+
+func newA2SCollector(config *config) *a2sCollector {
+	collector := &a2sCollector{config: config, snapshots: map[string]a2sSnapshot{}}
+	collector.loops = newPollGroup(collector.poll, func(target Target) {
+		collector.mu.Lock()
+		delete(collector.snapshots, target.Name)
+		collector.mu.Unlock()
+	})
+
+	return collector
+}
+
+func (c *a2sCollector) Name() string {
+	return "a2s"
+}
+
+// start launches a background polling loop for each a2s target.
+func (c *a2sCollector) start(ctx context.Context) {
+	c.loops.sync(ctx, filterTargets(c.config.Targets, protocolA2S))
+}
+
+// reload replaces the config, starting and stopping polling loops to match the new targets.
+func (c *a2sCollector) reload(ctx context.Context, config *config) {
+	c.mu.Lock()
+	c.config = config
+	c.mu.Unlock()
+
+	c.loops.sync(ctx, filterTargets(config.Targets, protocolA2S))
+}
+
+func (c *a2sCollector) poll(ctx context.Context, target Target) {
+	pollCtx, cancel := context.WithTimeout(ctx, target.Interval)
+	defer cancel()
+
+	attempted := time.Now()
+
+	result, errQuery := fetchA2S(pollCtx, target)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snap := c.snapshots[target.Name]
+
+	if errQuery != nil {
+		slog.Error("Failed to query server", slog.String("server", target.Name), slog.String("error", errQuery.Error()))
+
+		snap.errClass = classifyError(errQuery)
+	} else {
+		slog.Debug("Got a2s info", slog.String("map", result.info.Map), slog.String("server", target.Name))
+
+		snap = result
+		snap.lastSuccess = attempted
+	}
+
+	c.snapshots[target.Name] = snap
+}
+
+func fetchA2S(ctx context.Context, target Target) (a2sSnapshot, error) {
+	client, errClient := newA2SClient(ctx, target.addr())
+	if errClient != nil {
+		return a2sSnapshot{}, errClient
+	}
+
+	defer func() {
+		if errClose := client.Close(); errClose != nil {
+			slog.Error("Failed to close connection", slog.String("server", target.Name), slog.String("error", errClose.Error()))
+		}
+	}()
+
+	info, errInfo := client.info(ctx)
+	if errInfo != nil {
+		return a2sSnapshot{}, errInfo
+	}
+
+	players, errPlayers := client.players(ctx)
+	if errPlayers != nil {
+		return a2sSnapshot{}, errPlayers
+	}
+
+	// Many servers disable or truncate rules responses, so they are treated as optional.
+	rules, errRules := client.rules(ctx)
+	if errRules != nil {
+		slog.Debug("Failed to query rules", slog.String("server", target.Name), slog.String("error", errRules.Error()))
+	}
+
+	return a2sSnapshot{info: info, players: players, rules: rules}, nil
+}
+
+func createA2SDesc(namespace string, stat string, labels prometheus.Labels) *prometheus.Desc {
+	switch stat {
+	case "map":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "a2s", stat),
+			"The current map name",
+			nil, labels)
+	case "players":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "a2s", stat),
+			"The current player count, including bots",
+			nil, labels)
+	case "max_players":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "a2s", stat),
+			"The current server player limit",
+			nil, labels)
+	case "bots":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "a2s", stat),
+			"The current bot count",
+			nil, labels)
+	case "vac":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "a2s", stat),
+			"1 if the server is VAC secured",
+			nil, labels)
+	case "version":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "a2s", stat),
+			"The current game version",
+			nil, labels)
+	case "rules":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "a2s", stat),
+			"The number of server rules (public cvars) returned",
+			nil, labels)
+	case "player_score":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "a2s", stat),
+			"The current player score",
+			nil, labels)
+	case "player_duration":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "a2s", stat),
+			"The duration the player has been connected for in seconds",
+			nil, labels)
+	default:
+		slog.Warn("Unhandled stat Name", slog.String("stat", stat))
+	}
+
+	return nil
+}
+
+func (c *a2sCollector) Update(_ context.Context, metricCHan chan<- prometheus.Metric) error {
+	now := time.Now()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, server := range c.config.Targets {
+		if server.Protocol != protocolA2S {
+			continue
+		}
+
+		snap, found := c.snapshots[server.Name]
+		if !found {
+			continue
+		}
+
+		labels := c.config.targetLabels(server)
+
+		online := createStatusDesc(c.config.NameSpace, "online", labels)
+
+		if snap.online() {
+			metricCHan <- prometheus.MustNewConstMetric(online, prometheus.GaugeValue, 1)
+		} else {
+			metricCHan <- prometheus.MustNewConstMetric(online, prometheus.GaugeValue, 0)
+		}
+
+		for _, class := range errClasses {
+			scrapeError := createStatusDesc(c.config.NameSpace, "scrape_error", mergeLabels(labels, prometheus.Labels{"class": class}))
+
+			if snap.errClass == class {
+				metricCHan <- prometheus.MustNewConstMetric(scrapeError, prometheus.GaugeValue, 1)
+			} else {
+				metricCHan <- prometheus.MustNewConstMetric(scrapeError, prometheus.GaugeValue, 0)
+			}
+		}
+
+		if !snap.lastSuccess.IsZero() {
+			lastSuccess := createStatusDesc(c.config.NameSpace, "last_success_timestamp", labels)
+			age := createStatusDesc(c.config.NameSpace, "age_seconds", labels)
+
+			metricCHan <- prometheus.MustNewConstMetric(lastSuccess, prometheus.GaugeValue, float64(snap.lastSuccess.Unix()))
+			metricCHan <- prometheus.MustNewConstMetric(age, prometheus.GaugeValue, now.Sub(snap.lastSuccess).Seconds())
+		}
+
+		if !snap.online() {
+			continue
+		}
+
+		// Names are the only player identifier a2s provides, so per player series are only exported when the
+		// name label is enabled. Player names are not unique, only the first of any duplicates is exported.
+		exportPlayers := !c.config.Players.Disabled && slices.Contains(c.config.Players.Labels, playerLabelName)
+		seen := map[string]bool{}
+
+		for _, player := range snap.players {
+			if !exportPlayers || player.Name == "" || seen[player.Name] {
+				continue
+			}
+
+			seen[player.Name] = true
+
+			score := createA2SDesc(c.config.NameSpace, "player_score", mergeLabels(labels, prometheus.Labels{"name": player.Name}))
+			duration := createA2SDesc(c.config.NameSpace, "player_duration", mergeLabels(labels, prometheus.Labels{"name": player.Name}))
+
+			metricCHan <- prometheus.MustNewConstMetric(score, prometheus.GaugeValue, float64(player.Score))
+			metricCHan <- prometheus.MustNewConstMetric(duration, prometheus.GaugeValue, player.Duration.Seconds())
+		}
+
+		mapName := createA2SDesc(c.config.NameSpace, "map", mergeLabels(labels, prometheus.Labels{"map": snap.info.Map}))
+		players := createA2SDesc(c.config.NameSpace, "players", labels)
+		maxPlayers := createA2SDesc(c.config.NameSpace, "max_players", labels)
+		bots := createA2SDesc(c.config.NameSpace, "bots", labels)
+		vac := createA2SDesc(c.config.NameSpace, "vac", labels)
+		version := createA2SDesc(c.config.NameSpace, "version", mergeLabels(labels, prometheus.Labels{"version": snap.info.Version}))
+		rules := createA2SDesc(c.config.NameSpace, "rules", labels)
+
+		metricCHan <- prometheus.MustNewConstMetric(mapName, prometheus.GaugeValue, 1)
+		metricCHan <- prometheus.MustNewConstMetric(players, prometheus.GaugeValue, float64(snap.info.Players))
+		metricCHan <- prometheus.MustNewConstMetric(maxPlayers, prometheus.GaugeValue, float64(snap.info.MaxPlayers))
+		metricCHan <- prometheus.MustNewConstMetric(bots, prometheus.GaugeValue, float64(snap.info.Bots))
+
+		if snap.info.VAC {
+			metricCHan <- prometheus.MustNewConstMetric(vac, prometheus.GaugeValue, 1)
+		} else {
+			metricCHan <- prometheus.MustNewConstMetric(vac, prometheus.GaugeValue, 0)
+		}
+
+		metricCHan <- prometheus.MustNewConstMetric(version, prometheus.GaugeValue, 1)
+		metricCHan <- prometheus.MustNewConstMetric(rules, prometheus.GaugeValue, float64(len(snap.rules)))
+	}
+
+	return nil
+}
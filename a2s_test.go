@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type a2sTestWriter struct {
+	bytes.Buffer
+}
+
+func (w *a2sTestWriter) str(value string) *a2sTestWriter {
+	w.WriteString(value)
+	w.WriteByte(0)
+
+	return w
+}
+
+func (w *a2sTestWriter) u8(value byte) *a2sTestWriter {
+	w.WriteByte(value)
+
+	return w
+}
+
+func (w *a2sTestWriter) u16(value uint16) *a2sTestWriter {
+	_ = binary.Write(w, binary.LittleEndian, value)
+
+	return w
+}
+
+func (w *a2sTestWriter) u32(value uint32) *a2sTestWriter {
+	_ = binary.Write(w, binary.LittleEndian, value)
+
+	return w
+}
+
+func (w *a2sTestWriter) u64(value uint64) *a2sTestWriter {
+	_ = binary.Write(w, binary.LittleEndian, value)
+
+	return w
+}
+
+func testA2SInfoPayload() []byte {
+	writer := &a2sTestWriter{}
+	writer.u32(math.MaxUint32).u8(a2sInfoResponse).u8(17).
+		str("Kittyland Server").str("pl_upward").str("tf").str("Team Fortress").
+		u16(440).u8(24).u8(32).u8(1).u8('d').u8('l').u8(0).u8(1).
+		str("9227104").u8(0x80 | 0x10 | 0x20 | 0x01).u16(27015).u64(85568392921111111).str("payload,nocrits").u64(440)
+
+	return writer.Bytes()
+}
+
+func testA2SPlayersPayload() []byte {
+	writer := &a2sTestWriter{}
+	writer.u32(math.MaxUint32).u8(a2sPlayerResponse).u8(2).
+		u8(0).str("Dred").u32(12).u32(math.Float32bits(303.5)).
+		u8(0).str("smiley").u32(3).u32(math.Float32bits(60))
+
+	return writer.Bytes()
+}
+
+// splitA2SPayload splits a payload into source engine split packets.
+func splitA2SPayload(payload []byte, chunk int) [][]byte {
+	var packets [][]byte
+
+	total := (len(payload) + chunk - 1) / chunk
+
+	for number := range total {
+		end := min((number+1)*chunk, len(payload))
+		writer := &a2sTestWriter{}
+		writer.u32(0xfffffffe).u32(1234).u8(byte(total)).u8(byte(number)).u16(a2sMaxPacketSize)
+		writer.Write(payload[number*chunk : end])
+		packets = append(packets, writer.Bytes())
+	}
+
+	return packets
+}
+
+// startA2STestServer runs a fake server which requires a challenge for every request type and sends
+// player responses as split packets in reverse order.
+func startA2STestServer(t *testing.T) string {
+	t.Helper()
+
+	conn, errListen := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, errListen)
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	challenge := []byte{0x01, 0x02, 0x03, 0x04}
+
+	go func() {
+		buf := make([]byte, a2sMaxPacketSize)
+
+		for {
+			size, addr, errRead := conn.ReadFrom(buf)
+			if errRead != nil {
+				return
+			}
+
+			request := buf[:size]
+			if !bytes.HasSuffix(request, challenge) {
+				_, _ = conn.WriteTo(append([]byte{0xff, 0xff, 0xff, 0xff, a2sChallengeResponse}, challenge...), addr)
+
+				continue
+			}
+
+			switch request[4] {
+			case a2sInfoRequest:
+				_, _ = conn.WriteTo(testA2SInfoPayload(), addr)
+			case a2sPlayerRequest:
+				packets := splitA2SPayload(testA2SPlayersPayload(), 10)
+				for idx := len(packets) - 1; idx >= 0; idx-- {
+					_, _ = conn.WriteTo(packets[idx], addr)
+				}
+			case a2sRulesRequest:
+				writer := &a2sTestWriter{}
+				writer.u32(math.MaxUint32).u8(a2sRulesResponse).u16(2).str("mp_timelimit").str("30").str("tf_bot_quota").str("0")
+				_, _ = conn.WriteTo(writer.Bytes(), addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestA2SQuery(t *testing.T) {
+	addr := startA2STestServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	host, portStr, _ := net.SplitHostPort(addr)
+	target := Target{Name: "test", Host: host, Port: uint16(toIntDefault(portStr, 0)), Protocol: protocolA2S} //nolint:gosec
+
+	result, errFetch := fetchA2S(ctx, target)
+	require.NoError(t, errFetch)
+
+	require.Equal(t, &a2sInfo{
+		Protocol:    17,
+		Name:        "Kittyland Server",
+		Map:         "pl_upward",
+		Folder:      "tf",
+		Game:        "Team Fortress",
+		AppID:       440,
+		Players:     24,
+		MaxPlayers:  32,
+		Bots:        1,
+		ServerType:  "dedicated",
+		Environment: "linux",
+		Visibility:  false,
+		VAC:         true,
+		Version:     "9227104",
+		Port:        27015,
+		SteamID:     85568392921111111,
+		Keywords:    "payload,nocrits",
+		GameID:      440,
+	}, result.info)
+	require.Equal(t, []a2sPlayer{
+		{Name: "Dred", Score: 12, Duration: time.Duration(303.5 * float64(time.Second))},
+		{Name: "smiley", Score: 3, Duration: time.Minute},
+	}, result.players)
+	require.Equal(t, map[string]string{"mp_timelimit": "30", "tf_bot_quota": "0"}, result.rules)
+}
+
+func TestA2SCollectorPlayers(t *testing.T) {
+	conf := newConfig()
+	conf.Targets = []Target{{Name: "a", Protocol: protocolA2S}}
+
+	collector := newA2SCollector(conf)
+	collector.snapshots["a"] = a2sSnapshot{
+		info:        &a2sInfo{Map: "pl_upward", Players: 2},
+		players:     []a2sPlayer{{Name: "Dred", Score: 12, Duration: time.Minute}, {Name: "Dred", Score: 3}},
+		lastSuccess: time.Now(),
+	}
+
+	text := scrape(t, collector)
+	require.Contains(t, text, `srcds_a2s_players{server="a"} 2`)
+	require.NotContains(t, text, "srcds_a2s_player_score{")
+	require.NotContains(t, text, "srcds_a2s_player_duration{")
+
+	conf.Players = PlayerConfig{Labels: []string{playerLabelName}}
+	text = scrape(t, collector)
+	require.Contains(t, text, `srcds_a2s_player_score{name="Dred",server="a"} 12`)
+	require.Contains(t, text, `srcds_a2s_player_duration{name="Dred",server="a"} 60`)
+
+	conf.Players = PlayerConfig{Labels: []string{playerLabelName}, Disabled: true}
+	require.NotContains(t, scrape(t, collector), "srcds_a2s_player_score{")
+}
+
+func TestA2SParsePlayersInvalidUTF8(t *testing.T) {
+	payload := (&a2sTestWriter{}).u8(1).u8(0).str("abc\xe2\x82").u32(5).u32(math.Float32bits(1)).Bytes()
+
+	players, errParse := parseA2SPlayers(payload)
+	require.NoError(t, errParse)
+	require.Equal(t, "abc�", players[0].Name)
+}
+
+func TestA2SParseTruncated(t *testing.T) {
+	payload := testA2SInfoPayload()
+
+	_, errParse := parseA2SInfo(payload[5:20])
+	require.ErrorIs(t, errParse, errA2SShortPacket)
+}
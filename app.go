@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"net/http"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -15,13 +16,96 @@ import (
 var (
 	errPromRegister = errors.New("failed to register prometheus collection")
 	errHTTPListen   = errors.New("HTTP listener returned error")
+	errLogListen    = errors.New("failed to start log listener")
+	errInvalidPort  = errors.New("invalid port")
 )
 
-func start(ctx context.Context, config *config) error {
-	if errRegister := prometheus.Register(newRootCollector(ctx, config)); errRegister != nil {
+// application owns the components which depend on the config so they can be updated when it is reloaded.
+type application struct {
+	configPath     string
+	configRequired bool
+	config         atomic.Pointer[config]
+
+	root     *rootCollector
+	cache    *statusCache
+	poller   *statusPoller
+	a2s      *a2sCollector
+	logs     *logCollector
+	store    *playerStore
+	commands *commandCollector
+	reload   *reloadCollector
+}
+
+func newApplication(ctx context.Context, config *config, configPath string, configRequired bool) (*application, error) {
+	app := &application{
+		configPath:     configPath,
+		configRequired: configRequired,
+		root:           newRootCollector(ctx),
+		cache:          newStatusCache(),
+		a2s:            newA2SCollector(config),
+		reload:         newReloadCollector(),
+	}
+
+	app.config.Store(config)
+
+	if config.StorePath != "" {
+		store, errStore := openPlayerStore(config)
+		if errStore != nil {
+			return nil, errStore
+		}
+
+		app.store = store
+	}
+
+	app.poller = newStatusPoller(app.cache, app.store)
+	app.poller.start(ctx, config.Targets)
+
+	app.commands = newCommandCollector(config, app.poller.conns)
+	app.commands.start(ctx)
+	app.a2s.start(ctx)
+
+	if config.LogListenAddr != "" {
+		app.logs = newLogCollector(config)
+		if errLogs := app.logs.start(ctx); errLogs != nil {
+			return nil, errLogs
+		}
+	}
+
+	app.root.setCollectors(app.collectors(config)...)
+
+	return app, nil
+}
+
+func (app *application) currentConfig() *config {
+	return app.config.Load()
+}
+
+func (app *application) collectors(config *config) []CollectorHandler {
+	collectors := []CollectorHandler{newStatusCollector(config, app.cache), app.a2s, app.commands, app.reload}
+
+	if app.logs != nil {
+		collectors = append(collectors, app.logs)
+	}
+
+	if app.store != nil {
+		collectors = append(collectors, app.store)
+	}
+
+	return collectors
+}
+
+func start(ctx context.Context, config *config, configPath string, configRequired bool) error {
+	app, errApp := newApplication(ctx, config, configPath, configRequired)
+	if errApp != nil {
+		return errApp
+	}
+
+	if errRegister := prometheus.Register(app.root); errRegister != nil {
 		return errors.Join(errRegister, errPromRegister)
 	}
 
+	go app.watch(ctx)
+
 	handler := promhttp.HandlerFor(prometheus.DefaultGatherer,
 		promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})
 
@@ -29,6 +113,8 @@ func start(ctx context.Context, config *config) error {
 		handler.ServeHTTP(w, r)
 	})
 
+	http.HandleFunc(config.ProbePath, probeHandler(app.currentConfig))
+
 	httpServer := &http.Server{
 		Addr:           config.Addr(),
 		Handler:        nil,
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"maps"
+	"strconv"
+	"time"
+)
+
+// reconnectWindow is how long after leaving a returning player is counted as a reconnect rather than
+// a new visit.
+const reconnectWindow = time.Minute * 5
+
+// playerChurn accumulates the players joining and leaving a server, derived by comparing each status
+// with the one before it. Values are replaced rather than modified, so snapshots handed out by the
+// statusCache can be read without holding its lock.
+type playerChurn struct {
+	joins      uint64
+	leaves     uint64
+	reconnects uint64
+	// The session length of departed players, as cumulative histogram bucket counts.
+	sessionCount   uint64
+	sessionSum     float64
+	sessionBuckets map[float64]uint64
+	// departed holds the time that each steam id was last seen leaving, used to detect reconnects.
+	departed map[string]time.Time
+}
+
+// churnKey identifies a player across polls. Games without steam ids fall back to the userid, which is
+// unique per connection, so reconnects can not be detected for them.
+func churnKey(player statusPlayer) (string, bool) {
+	if player.steamID.Valid() {
+		return player.steamID.String(), true
+	}
+
+	return "userid/" + strconv.Itoa(player.userID), false
+}
+
+// humanPlayers returns the human players of the status keyed by churnKey.
+func humanPlayers(newStatus *status) map[string]statusPlayer {
+	players := map[string]statusPlayer{}
+
+	for _, player := range newStatus.Players {
+		if player.bot {
+			continue
+		}
+
+		key, _ := churnKey(player)
+		players[key] = player
+	}
+
+	return players
+}
+
+// observe returns the churn updated with the difference between the previous and current status. A player
+// whose userid changed between the polls has reconnected in between, and is counted as leaving and joining.
+func (c playerChurn) observe(previous *status, current *status, now time.Time) playerChurn {
+	next := c
+	next.departed = maps.Clone(c.departed)
+	next.sessionBuckets = maps.Clone(c.sessionBuckets)
+
+	if next.departed == nil {
+		next.departed = map[string]time.Time{}
+	}
+
+	if next.sessionBuckets == nil {
+		next.sessionBuckets = map[float64]uint64{}
+		for _, bucket := range sessionBuckets {
+			next.sessionBuckets[bucket] = 0
+		}
+	}
+
+	for steamID, left := range next.departed {
+		if now.Sub(left) > reconnectWindow {
+			delete(next.departed, steamID)
+		}
+	}
+
+	// The first status has nothing to compare against, its players have not been seen joining.
+	if previous == nil {
+		return next
+	}
+
+	before := humanPlayers(previous)
+	after := humanPlayers(current)
+
+	for key, player := range before {
+		if match, found := after[key]; found && match.userID == player.userID {
+			continue
+		}
+
+		next.leaves++
+		next.sessionCount++
+		next.sessionSum += float64(player.online)
+
+		for _, bucket := range sessionBuckets {
+			if float64(player.online) <= bucket {
+				next.sessionBuckets[bucket]++
+			}
+		}
+
+		if _, hasSteamID := churnKey(player); hasSteamID {
+			next.departed[key] = now
+		}
+	}
+
+	for key, player := range after {
+		if match, found := before[key]; found && match.userID == player.userID {
+			continue
+		}
+
+		next.joins++
+
+		if _, recent := next.departed[key]; recent {
+			next.reconnects++
+
+			delete(next.departed, key)
+		}
+	}
+
+	return next
+}
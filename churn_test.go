@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leighmacdonald/steamid/v4/steamid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlayerChurnObserve(t *testing.T) {
+	var (
+		alice = steamid.New(76561197960265729)
+		bob   = steamid.New(76561197960265730)
+		carol = steamid.New(76561197960265731)
+		now   = time.Now()
+	)
+
+	first := &status{Players: []statusPlayer{
+		{userID: 2, steamID: alice, online: 120},
+		{userID: 3, steamID: bob, online: 900},
+		{userID: 4, name: "Bot01", bot: true},
+	}}
+
+	churn := playerChurn{}.observe(nil, first, now)
+	require.Zero(t, churn.joins)
+	require.Zero(t, churn.leaves)
+
+	// bob leaves, carol joins and alice reconnects between polls with a new userid
+	second := &status{Players: []statusPlayer{
+		{userID: 5, steamID: alice, online: 10},
+		{userID: 6, steamID: carol, online: 30},
+	}}
+
+	churn = churn.observe(first, second, now.Add(time.Minute))
+	require.Equal(t, uint64(2), churn.joins)
+	require.Equal(t, uint64(2), churn.leaves)
+	require.Equal(t, uint64(1), churn.reconnects)
+	require.Equal(t, uint64(2), churn.sessionCount)
+	require.InDelta(t, 1020, churn.sessionSum, 0)
+	require.Equal(t, uint64(1), churn.sessionBuckets[300])
+	require.Equal(t, uint64(2), churn.sessionBuckets[1800])
+
+	// bob returning long after leaving is a new visit
+	third := &status{Players: []statusPlayer{
+		{userID: 5, steamID: alice, online: 70},
+		{userID: 6, steamID: carol, online: 90},
+		{userID: 7, steamID: bob, online: 5},
+	}}
+
+	previous := churn
+
+	churn = churn.observe(second, third, now.Add(time.Hour))
+	require.Equal(t, uint64(3), churn.joins)
+	require.Equal(t, uint64(1), churn.reconnects)
+	require.Empty(t, churn.departed)
+
+	// Earlier values are never modified
+	require.Equal(t, uint64(2), previous.joins)
+	require.Len(t, previous.departed, 1)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log/slog"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,20 +15,73 @@ type CollectorHandler interface {
 	Name() string
 }
 
+// labelValue replaces any invalid utf-8 in a value taken from a server, eg: a name truncated by the engine
+// part way through a multibyte character, which would otherwise make the scrape panic.
+func labelValue(value string) string {
+	return strings.ToValidUTF8(value, "\uFFFD")
+}
+
+// mergeLabels returns a new set of labels containing both sets. Values in extra take precedence. Values are
+// passed through labelValue, so no label built from server data can make the scrape panic.
+func mergeLabels(labels prometheus.Labels, extra prometheus.Labels) prometheus.Labels {
+	merged := make(prometheus.Labels, len(labels)+len(extra))
+
+	for key, value := range labels {
+		merged[key] = labelValue(value)
+	}
+
+	for key, value := range extra {
+		merged[key] = labelValue(value)
+	}
+
+	return merged
+}
+
+// newConstHistogram builds a histogram of the observations, for distributions which are recomputed from the
+// latest poll rather than accumulated.
+func newConstHistogram(desc *prometheus.Desc, buckets []float64, observations []float64) prometheus.Metric {
+	counts := make(map[float64]uint64, len(buckets))
+	for _, bucket := range buckets {
+		counts[bucket] = 0
+	}
+
+	var sum float64
+
+	for _, observation := range observations {
+		sum += observation
+
+		for _, bucket := range buckets {
+			if observation <= bucket {
+				counts[bucket]++
+			}
+		}
+	}
+
+	return prometheus.MustNewConstHistogram(desc, uint64(len(observations)), sum, counts)
+}
+
 type rootCollector struct {
-	// ctx cant get passed via update call as it's not in the defined prom interface so its here until
-	// background updates are enabled
-	ctx             context.Context //nolint:containedctx
-	statusCollector CollectorHandler
+	// ctx cant get passed via update call as it's not in the defined prom interface so its stored here
+	ctx        context.Context //nolint:containedctx
+	mu         sync.RWMutex
+	collectors []CollectorHandler
 }
 
-func newRootCollector(ctx context.Context, config *config) *rootCollector {
+func newRootCollector(ctx context.Context, collectors ...CollectorHandler) *rootCollector {
 	return &rootCollector{
-		ctx:             ctx,
-		statusCollector: newStatusCollector(config),
+		ctx:        ctx,
+		collectors: collectors,
 	}
 }
 
+// setCollectors replaces the active collectors, eg: after the config has been reloaded.
+func (n *rootCollector) setCollectors(collectors ...CollectorHandler) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.collectors = collectors
+}
+
 func (n *rootCollector) Name() string {
 	return "srcds_watch"
 }
@@ -49,7 +103,10 @@ func (n *rootCollector) Collect(outgoingCh chan<- prometheus.Metric) {
 		wgOut.Done()
 	}()
 
-	collectors := []CollectorHandler{n.statusCollector}
+	n.mu.RLock()
+	collectors := n.collectors
+	n.mu.RUnlock()
+
 	waitGroup := sync.WaitGroup{}
 
 	waitGroup.Add(len(collectors))
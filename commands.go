@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Supported CommandConfig.Type values.
+const (
+	commandTypeGauge   = "gauge"
+	commandTypeCounter = "counter"
+)
+
+var reMetricName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// CommandConfig defines an additional rcon command run against each rcon target, and how its output is turned
+// into a metric. The regex is applied to each line of the output, every matching line producing a series.
+type CommandConfig struct {
+	// Command is the rcon command to run, eg: tf_bot_quota.
+	Command string `yaml:"command"`
+	// Regex matches the lines of the output using named groups, eg: ^"tf_bot_quota" = "(?P<value>\d+)".
+	Regex string `yaml:"regex"`
+	// Metric is the metric name, prefixed with the name_space.
+	Metric string `yaml:"metric"`
+	Help   string `yaml:"help"`
+	// Type is either gauge (default) or counter.
+	Type string `yaml:"type"`
+	// Value names the group holding the metric value. When empty, the value is 1, which suits info style
+	// metrics carrying their values as labels.
+	Value string `yaml:"value"`
+	// Labels lists the groups exported as labels.
+	Labels []string `yaml:"labels"`
+	// Targets limits the command to the named targets, eg: those running a plugin. Defaults to every rcon
+	// target.
+	Targets []string `yaml:"targets"`
+}
+
+// appliesTo returns true if the command should be run against the target.
+func (c CommandConfig) appliesTo(target Target) bool {
+	return target.Protocol == protocolRCON && (len(c.Targets) == 0 || slices.Contains(c.Targets, target.Name))
+}
+
+// commandSample is a single value matched from the output of a command.
+type commandSample struct {
+	labels prometheus.Labels
+	value  float64
+}
+
+// commandCollector runs the configured commands against each rcon target in the background and exports
+// the values matched in their output. The connections are shared with the status poller.
+type commandCollector struct {
+	conns *connPool
+	loops *pollGroup
+
+	mu      sync.RWMutex
+	config  *config
+	regexes []*regexp.Regexp
+	// samples holds the samples of each command, by target name and command index.
+	samples map[string]map[int][]commandSample
+}
+
+func newCommandCollector(config *config, conns *connPool) *commandCollector {
+	collector := &commandCollector{conns: conns, samples: map[string]map[int][]commandSample{}}
+	collector.loops = newPollGroup(collector.poll, func(target Target) {
+		collector.mu.Lock()
+		delete(collector.samples, target.Name)
+		collector.mu.Unlock()
+	})
+
+	collector.setConfig(config)
+
+	return collector
+}
+
+func (c *commandCollector) Name() string {
+	return "commands"
+}
+
+// setConfig replaces the config and compiles the regexes, which have already been validated.
+func (c *commandCollector) setConfig(config *config) {
+	regexes := make([]*regexp.Regexp, len(config.Commands))
+	for idx, command := range config.Commands {
+		regexes[idx] = regexp.MustCompile(command.Regex)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.config = config
+	c.regexes = regexes
+	c.samples = map[string]map[int][]commandSample{}
+}
+
+// start launches a polling loop for each rcon target with at least one command.
+func (c *commandCollector) start(ctx context.Context) {
+	c.sync(ctx)
+}
+
+// reload replaces the config and restarts polling. Samples are cleared, since the command indexes may have
+// changed, and refilled by the next poll.
+func (c *commandCollector) reload(ctx context.Context, config *config) {
+	c.loops.sync(ctx, nil)
+	c.setConfig(config)
+	c.sync(ctx)
+}
+
+func (c *commandCollector) sync(ctx context.Context) {
+	c.mu.RLock()
+	config := c.config
+	c.mu.RUnlock()
+
+	var targets []Target //nolint:prealloc
+
+	for _, target := range config.Targets {
+		if !slices.ContainsFunc(config.Commands, func(command CommandConfig) bool { return command.appliesTo(target) }) {
+			continue
+		}
+
+		targets = append(targets, target)
+	}
+
+	c.loops.sync(ctx, targets)
+}
+
+func (c *commandCollector) poll(ctx context.Context, target Target) {
+	pollCtx, cancel := context.WithTimeout(ctx, target.Interval)
+	defer cancel()
+
+	c.mu.RLock()
+	commands := c.config.Commands
+	regexes := c.regexes
+	c.mu.RUnlock()
+
+	conn := c.conns.get(target)
+	results := map[int][]commandSample{}
+
+	for idx, command := range commands {
+		if !command.appliesTo(target) {
+			continue
+		}
+
+		body, errExec := conn.exec(pollCtx, command.Command)
+		if errExec != nil {
+			slog.Error("Failed to run command", slog.String("server", target.Name), slog.String("command", command.Command),
+				slog.String("error", errExec.Error()))
+
+			// Failures are connection level, the remaining commands would fail in the same way. The samples are
+			// dropped, as the status metrics are, rather than reporting stale values as current.
+			c.mu.Lock()
+			delete(c.samples, target.Name)
+			c.mu.Unlock()
+
+			return
+		}
+
+		results[idx] = matchCommand(command, regexes[idx], body)
+	}
+
+	c.mu.Lock()
+	c.samples[target.Name] = results
+	c.mu.Unlock()
+}
+
+// matchCommand returns a sample for each line of the output matching the regex. Lines producing the same
+// labels replace the earlier sample, so duplicate series are never exported.
+func matchCommand(command CommandConfig, regex *regexp.Regexp, body string) []commandSample {
+	var samples []commandSample
+
+	for _, line := range strings.Split(body, "\n") {
+		match := regex.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match == nil {
+			continue
+		}
+
+		value := 1.0
+
+		if command.Value != "" {
+			parsed, errParse := strconv.ParseFloat(group(regex, match, command.Value), 64)
+			if errParse != nil {
+				slog.Debug("Invalid command value", slog.String("command", command.Command), slog.String("line", line))
+
+				continue
+			}
+
+			value = parsed
+		}
+
+		labels := prometheus.Labels{}
+		for _, name := range command.Labels {
+			labels[name] = labelValue(group(regex, match, name))
+		}
+
+		sample := commandSample{labels: labels, value: value}
+
+		idx := slices.IndexFunc(samples, func(existing commandSample) bool { return maps.Equal(existing.labels, labels) })
+		if idx >= 0 {
+			samples[idx] = sample
+		} else {
+			samples = append(samples, sample)
+		}
+	}
+
+	return samples
+}
+
+func (c *commandCollector) Update(_ context.Context, metricCHan chan<- prometheus.Metric) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, server := range c.config.Targets {
+		results, found := c.samples[server.Name]
+		if !found {
+			continue
+		}
+
+		labels := c.config.targetLabels(server)
+
+		for idx, samples := range results {
+			command := c.config.Commands[idx]
+
+			help := command.Help
+			if help == "" {
+				help = "The value matched from the output of the " + command.Command + " command"
+			}
+
+			valueType := prometheus.GaugeValue
+			if command.Type == commandTypeCounter {
+				valueType = prometheus.CounterValue
+			}
+
+			for _, sample := range samples {
+				desc := prometheus.NewDesc(prometheus.BuildFQName(c.config.NameSpace, "", command.Metric), help,
+					nil, mergeLabels(labels, sample.labels))
+				metricCHan <- prometheus.MustNewConstMetric(desc, valueType, sample.value)
+			}
+		}
+	}
+
+	return nil
+}
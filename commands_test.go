@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchCommand(t *testing.T) {
+	command := CommandConfig{Command: "sm plugins list", Labels: []string{"plugin", "version"}}
+	regex := regexp.MustCompile(`^\s*\d+\s+"(?P<plugin>[^"]+)"\s+\((?P<version>[^)]+)\)`)
+
+	samples := matchCommand(command, regex, "[SM] Listing 3 plugins:\r\n"+
+		"  01 \"Admin File Reader\" (1.12.0.7110) by AlliedModders LLC\r\n"+
+		"  02 \"Basic Commands\" (1.12.0.7110) by AlliedModders LLC\r\n"+
+		"  03 \"Basic Commands\" (1.12.0.7110) by AlliedModders LLC\r\n")
+	require.Equal(t, []commandSample{
+		{labels: prometheus.Labels{"plugin": "Admin File Reader", "version": "1.12.0.7110"}, value: 1},
+		{labels: prometheus.Labels{"plugin": "Basic Commands", "version": "1.12.0.7110"}, value: 1},
+	}, samples)
+
+	command = CommandConfig{Command: "mp_timelimit", Value: "value"}
+	regex = regexp.MustCompile(`^"mp_timelimit" = "(?P<value>[^"]*)"`)
+
+	require.Equal(t, []commandSample{{labels: prometheus.Labels{}, value: 30}},
+		matchCommand(command, regex, `"mp_timelimit" = "30" ( def. "0" ) min. 0.000000`))
+	require.Empty(t, matchCommand(command, regex, `"mp_timelimit" = "" ( def. "0" )`))
+
+	command = CommandConfig{Command: "sm plugins list", Labels: []string{"plugin"}}
+	regex = regexp.MustCompile(`^"(?P<plugin>[^"]+)"`)
+
+	require.Equal(t, []commandSample{{labels: prometheus.Labels{"plugin": "caf�"}, value: 1}},
+		matchCommand(command, regex, "\"caf\xc3\""))
+}
+
+func TestCommandCollector(t *testing.T) {
+	addr := startGoldSrcTestServer(t, "secret")
+
+	host, portValue, errSplit := net.SplitHostPort(addr)
+	require.NoError(t, errSplit)
+
+	port, errPort := strconv.ParseUint(portValue, 10, 16)
+	require.NoError(t, errPort)
+
+	conf := newConfig()
+	require.NoError(t, conf.read(strings.NewReader(`targets:
+  - name: a
+    host: `+host+`
+    port: `+strconv.FormatUint(port, 10)+`
+    password: secret
+    game: goldsrc
+commands:
+  - command: stats
+    regex: '^\s*\d+\.\d+\s+\d+\.\d+\s+\d+\.\d+\s+(?P<uptime>\d+)'
+    metric: uptime_minutes
+    help: The server uptime in minutes
+    value: uptime
+  - command: status
+    regex: '^map\s+:\s+(?P<level>\S+)'
+    metric: level_info
+    labels: [level]
+`)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conns := newConnPool()
+	defer conns.close()
+
+	collector := newCommandCollector(conf, conns)
+	collector.poll(ctx, conf.Targets[0])
+
+	text := scrape(t, collector)
+	require.Contains(t, text, "# HELP srcds_uptime_minutes The server uptime in minutes")
+	require.Contains(t, text, `srcds_uptime_minutes{server="a"} 10`)
+	require.Contains(t, text, `srcds_level_info{level="de_dust2",server="a"} 1`)
+
+	// A failed poll drops the samples rather than reporting stale values
+	failing := conf.Targets[0]
+	failing.Password = "wrong"
+	collector.poll(ctx, failing)
+
+	text = scrape(t, collector)
+	require.NotContains(t, text, "srcds_uptime_minutes")
+	require.NotContains(t, text, "srcds_level_info")
+
+	collector.poll(ctx, conf.Targets[0])
+
+	// Commands are only run against the listed targets
+	conf.Commands[0].Targets = []string{"b"}
+	collector.reload(ctx, conf)
+	collector.poll(ctx, conf.Targets[0])
+
+	text = scrape(t, collector)
+	require.NotContains(t, text, "srcds_uptime_minutes")
+	require.Contains(t, text, `srcds_level_info{level="de_dust2",server="a"} 1`)
+
+	cancel()
+	collector.loops.wait()
+}
+
+func TestConfigCommands(t *testing.T) {
+	conf := newConfig()
+	errRead := conf.read(strings.NewReader(`commands:
+  - regex: '(?P<value>\d+'
+    metric: 1bad
+    type: summary
+  - command: tf_bot_quota
+    regex: '^"tf_bot_quota" = "(?P<value>\d+)"'
+    metric: tf_bot_quota
+    value: quota
+    labels: [value, missing, server]
+    targets: [nowhere]
+  - command: tf_bot_quota
+    regex: '^"tf_bot_quota" = "(?P<value>\d+)"'
+    metric: tf_bot_quota
+    value: value
+`))
+
+	var errs configErrors
+
+	require.ErrorAs(t, errRead, &errs)
+	require.Equal(t, configErrors{
+		{line: 2, field: "commands[0].command", message: "is required"},
+		{line: 3, field: "commands[0].metric", message: `invalid metric name "1bad"`},
+		{line: 4, field: "commands[0].type", message: "must be one of gauge or counter"},
+		{line: 2, field: "commands[0].regex", message: "must be a valid regular expression"},
+		{line: 10, field: "commands[1].targets", message: `unknown target "nowhere"`},
+		{line: 8, field: "commands[1].value", message: `regex has no group named "quota"`},
+		{line: 9, field: "commands[1].labels[1]", message: `regex has no group named "missing"`},
+		{line: 9, field: "commands[1].labels[2]", message: `regex has no group named "server"`},
+		{line: 13, field: "commands[2].metric", message: `duplicate metric name "tf_bot_quota", first used by commands[1]`},
+	}, errs)
+
+	conf = newConfig()
+	errRead = conf.read(strings.NewReader(`labels:
+  region: eu
+targets:
+  - name: a
+    host: 10.0.0.1
+    port: 27015
+    password: secret
+    labels:
+      mode: payload
+commands:
+  - command: stats
+    regex: '^(?P<region>\w+) (?P<mode>\w+) (?P<value>\d+)$'
+    metric: stats_cpu
+    value: value
+    labels: [region, mode]
+`))
+
+	require.ErrorAs(t, errRead, &errs)
+	require.Equal(t, configErrors{
+		{line: 13, field: "commands[0].metric", message: `metric name "stats_cpu" collides with the built in stats_ metrics`},
+		{line: 15, field: "commands[0].labels[0]", message: `label "region" is already set by labels`},
+		{line: 15, field: "commands[0].labels[1]", message: `label "mode" is already set by targets[0].labels`},
+	}, errs)
+}
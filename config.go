@@ -1,14 +1,32 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"net"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"gopkg.in/yaml.v3"
 )
 
+const (
+	defaultPollInterval = time.Second * 15
+	envPrefix           = "SRCDS_WATCH_"
+)
+
+// Supported Target.Protocol values.
+const (
+	protocolRCON = "rcon"
+	protocolA2S  = "a2s"
+)
+
 type versionInfo struct {
 	version string
 	commit  string
@@ -29,20 +47,69 @@ type Target struct {
 	Host     string `yaml:"host"`
 	Port     uint16 `yaml:"port"`
 	Password string `yaml:"password"`
-	Name     string `yaml:"name"`
+	// PasswordFile is read to obtain the password, eg: a mounted docker or kubernetes secret.
+	PasswordFile string `yaml:"password_file"`
+	// PasswordEnv names an environment variable to read the password from.
+	PasswordEnv string `yaml:"password_env"`
+	Name        string `yaml:"name"`
+	// Interval controls how often the target is polled in the background. Defaults to config.PollInterval.
+	Interval time.Duration `yaml:"interval"`
+	// Protocol selects how the server is queried, either rcon (default) or a2s. The a2s protocol does not
+	// require a password but exposes less information.
+	Protocol string `yaml:"protocol"`
+	// Game selects the status parser, one of tf2 (default), css, csgo, cs2, l4d2, gmod or
+	// goldsrc. The goldsrc game also switches to the udp rcon protocol used by HLDS.
+	Game string `yaml:"game"`
+	// LogSecret is the sv_logsecret value of the server. When set, only log packets carrying the secret are
+	// attributed to this target.
+	LogSecret string `yaml:"log_secret"`
+	// Labels are added to every metric of the target, overriding any global labels with the same name.
+	Labels map[string]string `yaml:"labels"`
+}
+
+// Module holds the credentials used for ad-hoc targets requested via the probe endpoint.
+type Module struct {
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+	PasswordEnv  string `yaml:"password_env"`
+	Protocol     string `yaml:"protocol"`
+	Game         string `yaml:"game"`
+	// AllowedTargets lists the hostnames, ips and cidr networks the module may be used to probe. Required for
+	// rcon modules, so the password is never sent to an address chosen by the caller.
+	AllowedTargets []string `yaml:"allowed_targets"`
 }
 
 func (t Target) addr() string {
-	return fmt.Sprintf("%s:%d", t.Host, t.Port)
+	return net.JoinHostPort(t.Host, strconv.Itoa(int(t.Port)))
 }
 
 type config struct {
-	ListenHost  string   `yaml:"listen_host"`
-	ListenPort  uint16   `yaml:"listen_port"`
-	LogLevel    string   `yaml:"log_level"`
-	MetricsPath string   `yaml:"metrics_path"`
-	NameSpace   string   `yaml:"name_space"`
-	Targets     []Target `yaml:"targets"`
+	ListenHost   string        `yaml:"listen_host"`
+	ListenPort   uint16        `yaml:"listen_port"`
+	LogLevel     string        `yaml:"log_level"`
+	MetricsPath  string        `yaml:"metrics_path"`
+	ProbePath    string        `yaml:"probe_path"`
+	NameSpace    string        `yaml:"name_space"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// LogListenAddr is the udp address to receive logs on, servers should use logaddress_add to send
+	// to it. Disabled when empty.
+	LogListenAddr string `yaml:"log_listen_addr"`
+	// StorePath is the bbolt database file recording player sightings, used to report unique players
+	// over days. Disabled when empty.
+	StorePath string            `yaml:"store_path"`
+	Targets   []Target          `yaml:"targets"`
+	Modules   map[string]Module `yaml:"modules"`
+	// Labels are added to every metric.
+	Labels map[string]string `yaml:"labels"`
+	// Players controls which player attributes are exported.
+	Players PlayerConfig `yaml:"players"`
+	// Commands are additional rcon commands whose output is exported as metrics.
+	Commands []CommandConfig `yaml:"commands"`
+}
+
+// targetLabels returns the constant labels applied to every metric of the target.
+func (c *config) targetLabels(target Target) prometheus.Labels {
+	return mergeLabels(mergeLabels(c.Labels, target.Labels), prometheus.Labels{"server": target.Name})
 }
 
 func (c *config) Addr() string {
@@ -51,19 +118,96 @@ func (c *config) Addr() string {
 
 func newConfig() *config {
 	return &config{
-		ListenHost:  "0.0.0.0",
-		ListenPort:  8767,
-		MetricsPath: "/metrics",
-		Targets:     nil,
-		NameSpace:   "srcds",
+		ListenHost:   "0.0.0.0",
+		ListenPort:   8767,
+		MetricsPath:  "/metrics",
+		ProbePath:    "/probe",
+		PollInterval: defaultPollInterval,
+		Targets:      nil,
+		NameSpace:    "srcds",
 	}
 }
 
+var errConfigNotFound = errors.New("config file does not exist")
+
+// readConfigFile loads the config from configPath and resolves the secrets it references. A missing file is
+// only an error when required, otherwise the config is built from the defaults and environment variables alone.
+func readConfigFile(configPath string, required bool) (*config, error) {
+	conf, errParse := parseConfigFile(configPath, required)
+	if errParse != nil {
+		return nil, errParse
+	}
+
+	if errSecrets := conf.loadSecrets(); errSecrets != nil {
+		return nil, errSecrets
+	}
+
+	return conf, nil
+}
+
+// parseConfigFile loads and validates the config from configPath without resolving its secrets, so a config
+// can be checked away from the deployment providing its password files and environment variables.
+func parseConfigFile(configPath string, required bool) (*config, error) {
+	conf := newConfig()
+
+	if !exists(configPath) {
+		if required {
+			return nil, errConfigNotFound
+		}
+
+		return conf, conf.parse(strings.NewReader(""))
+	}
+
+	configFile, errOpen := os.Open(configPath)
+	if errOpen != nil {
+		return nil, errors.Wrap(errOpen, "Failed to open config file")
+	}
+
+	defer func() {
+		_ = configFile.Close()
+	}()
+
+	if errParse := conf.parse(configFile); errParse != nil {
+		return nil, errParse
+	}
+
+	return conf, nil
+}
+
+// read parses the config and resolves the secrets it references.
 func (c *config) read(reader io.Reader) error {
-	if err := yaml.NewDecoder(reader).Decode(c); err != nil {
+	if errParse := c.parse(reader); errParse != nil {
+		return errParse
+	}
+
+	return c.loadSecrets()
+}
+
+// parse decodes the yaml config, applies any environment variable overrides, fills in defaults and then
+// validates the result. Unknown keys are rejected. An empty reader is valid, allowing the config to be
+// provided entirely by the environment.
+func (c *config) parse(reader io.Reader) error {
+	body, errRead := io.ReadAll(reader)
+	if errRead != nil {
+		return errors.Wrap(errRead, "Could not read config")
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
 		return errors.Wrap(err, "Could not decode config")
 	}
 
+	var root yaml.Node
+	if errNode := yaml.Unmarshal(body, &root); errNode != nil {
+		return errors.Wrap(errNode, "Could not decode config")
+	}
+
+	if errEnv := c.applyEnv(os.LookupEnv); errEnv != nil {
+		return errEnv
+	}
+
 	if c.NameSpace == "" {
 		c.NameSpace = "srcds"
 	}
@@ -72,6 +216,10 @@ func (c *config) read(reader io.Reader) error {
 		c.MetricsPath = "/metrics"
 	}
 
+	if c.ProbePath == "" {
+		c.ProbePath = "/probe"
+	}
+
 	if c.ListenHost == "" {
 		c.ListenHost = "0.0.0.0"
 	}
@@ -84,5 +232,128 @@ func (c *config) read(reader io.Reader) error {
 		c.LogLevel = "info"
 	}
 
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaultPollInterval
+	}
+
+	if c.Players.IPMode == "" {
+		c.Players.IPMode = ipModeHash
+	}
+
+	for idx := range c.Targets {
+		if c.Targets[idx].Interval <= 0 {
+			c.Targets[idx].Interval = c.PollInterval
+		}
+
+		if c.Targets[idx].Protocol == "" {
+			c.Targets[idx].Protocol = protocolRCON
+		}
+
+		if c.Targets[idx].Game == "" {
+			c.Targets[idx].Game = gameTF2
+		}
+	}
+
+	for idx := range c.Commands {
+		if c.Commands[idx].Type == "" {
+			c.Commands[idx].Type = commandTypeGauge
+		}
+	}
+
+	for name, module := range c.Modules {
+		if module.Protocol == "" {
+			module.Protocol = protocolRCON
+		}
+
+		if module.Game == "" {
+			module.Game = gameTF2
+		}
+
+		c.Modules[name] = module
+	}
+
+	return c.validate(newConfigLines(&root))
+}
+
+// applyEnv overrides config values using SRCDS_WATCH_<YAML_KEY> environment variables, eg:
+// SRCDS_WATCH_LISTEN_PORT=8080. Non string values, including lists and maps such as targets, are
+// parsed as yaml.
+func (c *config) applyEnv(lookup func(string) (string, bool)) error {
+	value := reflect.ValueOf(c).Elem()
+
+	for idx := range value.NumField() {
+		key, _, _ := strings.Cut(value.Type().Field(idx).Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+
+		envKey := envPrefix + strings.ToUpper(key)
+
+		envValue, found := lookup(envKey)
+		if !found {
+			continue
+		}
+
+		field := value.Field(idx)
+		if field.Kind() == reflect.String {
+			field.SetString(envValue)
+
+			continue
+		}
+
+		if errDecode := yaml.Unmarshal([]byte(envValue), field.Addr().Interface()); errDecode != nil {
+			return errors.Wrapf(errDecode, "Invalid value for %s", envKey)
+		}
+	}
+
+	return nil
+}
+
+// loadSecrets resolves the password_file and password_env options of targets and modules. When set,
+// they take precedence over any plaintext password.
+func (c *config) loadSecrets() error {
+	for idx, target := range c.Targets {
+		password, errPassword := readSecret(target.Password, target.PasswordFile, target.PasswordEnv)
+		if errPassword != nil {
+			return errors.Wrapf(errPassword, "Could not load password for target %s", target.Name)
+		}
+
+		c.Targets[idx].Password = password
+	}
+
+	for name, module := range c.Modules {
+		password, errPassword := readSecret(module.Password, module.PasswordFile, module.PasswordEnv)
+		if errPassword != nil {
+			return errors.Wrapf(errPassword, "Could not load password for module %s", name)
+		}
+
+		module.Password = password
+		c.Modules[name] = module
+	}
+
 	return nil
 }
+
+var errSecretEnvUnset = errors.New("password environment variable is not set")
+
+func readSecret(value string, filePath string, envKey string) (string, error) {
+	if filePath != "" {
+		contents, errRead := os.ReadFile(filePath)
+		if errRead != nil {
+			return "", errors.Wrap(errRead, "Could not read password file")
+		}
+
+		return strings.TrimRight(string(contents), "\r\n"), nil
+	}
+
+	if envKey != "" {
+		envValue, found := os.LookupEnv(envKey)
+		if !found {
+			return "", errors.Wrap(errSecretEnvUnset, envKey)
+		}
+
+		return envValue, nil
+	}
+
+	return value, nil
+}
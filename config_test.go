@@ -0,0 +1,162 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigEnvOverrides(t *testing.T) {
+	conf := newConfig()
+	env := map[string]string{
+		"SRCDS_WATCH_LISTEN_PORT":   "9000",
+		"SRCDS_WATCH_METRICS_PATH":  "/custom",
+		"SRCDS_WATCH_POLL_INTERVAL": "1m",
+		"SRCDS_WATCH_TARGETS":       `[{name: env, host: 10.0.0.1, port: 27015, password: pass}]`,
+	}
+
+	require.NoError(t, conf.applyEnv(func(key string) (string, bool) {
+		value, found := env[key]
+
+		return value, found
+	}))
+	require.Equal(t, uint16(9000), conf.ListenPort)
+	require.Equal(t, "/custom", conf.MetricsPath)
+	require.Equal(t, time.Minute, conf.PollInterval)
+	require.Equal(t, []Target{{Name: "env", Host: "10.0.0.1", Port: 27015, Password: "pass"}}, conf.Targets)
+
+	require.Error(t, conf.applyEnv(func(key string) (string, bool) {
+		return "not a port", key == "SRCDS_WATCH_LISTEN_PORT"
+	}))
+}
+
+func TestConfigSecrets(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("from-file\n"), 0o600))
+	t.Setenv("TEST_RCON_PASSWORD", "from-env")
+
+	conf := newConfig()
+	require.NoError(t, conf.read(strings.NewReader(`
+targets:
+  - name: file
+    host: 10.0.0.1
+    port: 27015
+    password_file: `+passwordFile+`
+  - name: env
+    host: 10.0.0.1
+    port: 27016
+    password_env: TEST_RCON_PASSWORD
+`)))
+	require.Equal(t, "from-file", conf.Targets[0].Password)
+	require.Equal(t, "from-env", conf.Targets[1].Password)
+
+	conf = newConfig()
+	require.ErrorIs(t, conf.read(strings.NewReader(`
+targets:
+  - name: env
+    host: 10.0.0.1
+    port: 27015
+    password_env: TEST_RCON_PASSWORD_UNSET
+`)), errSecretEnvUnset)
+}
+
+func TestParseConfigFileSkipsSecrets(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "srcds_watch.yml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+targets:
+  - name: env
+    host: 10.0.0.1
+    port: 27015
+    password_env: TEST_RCON_PASSWORD_UNSET
+`), 0o600))
+
+	conf, errParse := parseConfigFile(configPath, true)
+	require.NoError(t, errParse)
+	require.Empty(t, conf.Targets[0].Password)
+
+	_, errRead := readConfigFile(configPath, true)
+	require.ErrorIs(t, errRead, errSecretEnvUnset)
+}
+
+func TestConfigValidate(t *testing.T) {
+	conf := newConfig()
+	errRead := conf.read(strings.NewReader(`log_level: trace
+targets:
+  - name: a
+    host: 10.0.0.1
+    port: 27015
+    password: pass
+  - name: a
+    host: "bad host!"
+    password: pass
+  - host: example.com
+    port: 27015
+    protocol: gopher
+  - name: b
+    host: example.com
+    port: 27016
+    game: quake
+`))
+
+	var errs configErrors
+
+	require.ErrorAs(t, errRead, &errs)
+	require.Equal(t, configErrors{
+		{line: 1, field: "log_level", message: "must be one of debug, info, warn or error"},
+		{line: 7, field: "targets[1].name", message: `duplicate target name "a", first used by targets[0]`},
+		{line: 8, field: "targets[1].host", message: `"bad host!" is not a valid hostname or ip address`},
+		{line: 7, field: "targets[1].port", message: "is required"},
+		{line: 10, field: "targets[2].name", message: "is required"},
+		{line: 12, field: "targets[2].protocol", message: `unknown protocol "gopher"`},
+		{line: 13, field: "targets[3].password", message: "one of password, password_file or password_env is required for the rcon protocol"},
+		{line: 16, field: "targets[3].game", message: `unknown game "quake", must be one of tf2, css, csgo, cs2, l4d2, gmod, goldsrc`},
+	}, errs)
+}
+
+func TestConfigUnknownField(t *testing.T) {
+	conf := newConfig()
+	errRead := conf.read(strings.NewReader(`targets:
+  - name: a
+    host: 10.0.0.1
+    port: 27015
+    passwd: pass
+`))
+	require.ErrorContains(t, errRead, "line 5: field passwd not found")
+}
+
+func TestConfigLabels(t *testing.T) {
+	conf := newConfig()
+	require.NoError(t, conf.read(strings.NewReader(`labels:
+  region: us
+  provider: ovh
+targets:
+  - name: a
+    host: 10.0.0.1
+    port: 27015
+    password: pass
+    labels:
+      provider: vultr
+      mode: payload
+`)))
+	require.Equal(t, prometheus.Labels{"server": "a", "region": "us", "provider": "vultr", "mode": "payload"},
+		conf.targetLabels(conf.Targets[0]))
+
+	conf = newConfig()
+	errRead := conf.read(strings.NewReader(`labels:
+  server: x
+  9bad: x
+`))
+
+	var errs configErrors
+
+	require.ErrorAs(t, errRead, &errs)
+	require.Equal(t, configErrors{
+		{line: 3, field: "labels.9bad", message: "invalid label name"},
+		{line: 2, field: "labels.server", message: "reserved label name"},
+	}, errs)
+}
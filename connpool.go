@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/leighmacdonald/rcon/rcon"
+)
+
+const (
+	rconDialTimeout = time.Second * 8
+	backoffMin      = time.Second
+	backoffMax      = time.Minute * 2
+)
+
+var (
+	errBackoff = errors.New("waiting to reconnect")
+	errDial    = errors.New("failed to connect")
+	errAuth    = errors.New("failed to authenticate")
+	errExec    = errors.New("failed to execute rcon command")
+)
+
+// rconClient is implemented by the source rcon client and goldSrcClient.
+type rconClient interface {
+	Exec(command string) (string, error)
+	Close() error
+}
+
+// rconConn wraps a single persistent, authenticated rcon connection to a target. Commands are
+// serialised so that concurrent callers cannot interleave their responses.
+type rconConn struct {
+	target      Target
+	mu          sync.Mutex
+	conn        rconClient
+	failures    int
+	nextAttempt time.Time
+	lastErr     error
+}
+
+// exec runs the command over the persistent connection, dialing a new connection if required.
+// If an established connection turns out to be broken, it is discarded and the command is retried
+// once over a fresh connection.
+func (c *rconConn) exec(ctx context.Context, command string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reused := c.conn != nil
+
+	body, errCmd := c.execLocked(ctx, command)
+	if errCmd != nil && reused && !errors.Is(errCmd, errBackoff) && ctx.Err() == nil {
+		slog.Debug("Retrying command on new connection", slog.String("server", c.target.Name),
+			slog.String("error", errCmd.Error()))
+
+		return c.execLocked(ctx, command)
+	}
+
+	return body, errCmd
+}
+
+func (c *rconConn) execLocked(ctx context.Context, command string) (string, error) {
+	if c.conn == nil {
+		if errDial := c.dialLocked(ctx); errDial != nil {
+			return "", errDial
+		}
+	}
+
+	conn := c.conn
+
+	// The rcon library does not accept a context, so closing the connection is the only way to
+	// abort a blocked read.
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+
+	body, errCmd := conn.Exec(command)
+	if !stop() {
+		c.conn = nil
+
+		return "", errors.Join(context.Cause(ctx), errExec)
+	}
+
+	if errCmd != nil {
+		c.closeLocked()
+
+		return "", errors.Join(errCmd, errExec)
+	}
+
+	return body, nil
+}
+
+func (c *rconConn) dialLocked(ctx context.Context) error {
+	now := time.Now()
+	if now.Before(c.nextAttempt) {
+		return errors.Join(fmt.Errorf("%w: next attempt in %s", errBackoff,
+			c.nextAttempt.Sub(now).Round(time.Second)), c.lastErr)
+	}
+
+	conn, errConn := c.dial(ctx)
+	if errConn != nil {
+		c.failures++
+		c.nextAttempt = now.Add(backoff(c.failures))
+
+		if errors.Is(errConn, rcon.ErrAuthFailed) || errors.Is(errConn, rcon.ErrInvalidAuthResponse) {
+			c.lastErr = errors.Join(errConn, errAuth)
+		} else {
+			c.lastErr = errors.Join(errConn, errDial)
+		}
+
+		return c.lastErr
+	}
+
+	if c.failures > 0 {
+		slog.Info("Reconnected", slog.String("server", c.target.Name), slog.Int("failures", c.failures))
+	}
+
+	c.conn = conn
+	c.failures = 0
+	c.nextAttempt = time.Time{}
+	c.lastErr = nil
+
+	return nil
+}
+
+func (c *rconConn) dial(ctx context.Context) (rconClient, error) {
+	if c.target.Game == gameGoldSrc {
+		return dialGoldSrc(ctx, c.target.addr(), c.target.Password, rconDialTimeout)
+	}
+
+	return rcon.Dial(ctx, c.target.addr(), c.target.Password, rconDialTimeout)
+}
+
+func (c *rconConn) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closeLocked()
+}
+
+func (c *rconConn) closeLocked() {
+	if c.conn == nil {
+		return
+	}
+
+	if errClose := c.conn.Close(); errClose != nil {
+		slog.Debug("Failed to close connection", slog.String("server", c.target.Name), slog.String("error", errClose.Error()))
+	}
+
+	c.conn = nil
+}
+
+// backoff returns an exponentially increasing delay with full jitter applied to the upper half,
+// so that many targets failing at once do not reconnect in lockstep.
+func backoff(failures int) time.Duration {
+	delay := backoffMax
+	if failures < 16 {
+		delay = min(backoffMin<<(failures-1), backoffMax)
+	}
+
+	half := delay / 2
+
+	return half + rand.N(half+1) //nolint:gosec
+}
+
+// connPool holds one rconConn per target, keyed by Target.Name.
+type connPool struct {
+	mu    sync.Mutex
+	conns map[string]*rconConn
+}
+
+func newConnPool() *connPool {
+	return &connPool{conns: map[string]*rconConn{}}
+}
+
+// get returns the connection of the target. A connection created for a different version of the target, eg:
+// by a poll loop which has not yet stopped after a reload, is closed and replaced, so a stale host or
+// password is never used alongside the current one.
+func (p *connPool) get(target Target) *rconConn {
+	p.mu.Lock()
+
+	stale, found := p.conns[target.Name]
+	if found && reflect.DeepEqual(stale.target, target) {
+		p.mu.Unlock()
+
+		return stale
+	}
+
+	conn := &rconConn{target: target}
+	p.conns[target.Name] = conn
+	p.mu.Unlock()
+
+	// Closing waits for any command in progress, so it is done without holding the pool lock.
+	if found {
+		stale.close()
+	}
+
+	return conn
+}
+
+func (p *connPool) remove(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if conn, found := p.conns[name]; found {
+		conn.close()
+		delete(p.conns, name)
+	}
+}
+
+func (p *connPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for name, conn := range p.conns {
+		conn.close()
+		delete(p.conns, name)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	for failures := 1; failures < 32; failures++ {
+		delay := backoff(failures)
+		require.GreaterOrEqual(t, delay, backoffMin/2)
+		require.LessOrEqual(t, delay, backoffMax)
+	}
+
+	require.GreaterOrEqual(t, backoff(100), backoffMax/2)
+}
+
+func TestConnPoolGet(t *testing.T) {
+	pool := newConnPool()
+	target := Target{Name: "a", Host: "10.0.0.1", Port: 27015, Password: "old"}
+
+	conn := pool.get(target)
+	require.Same(t, conn, pool.get(target))
+
+	target.Password = "new"
+	replaced := pool.get(target)
+	require.NotSame(t, conn, replaced)
+	require.Equal(t, "new", replaced.target.Password)
+	require.Same(t, replaced, pool.get(target))
+}
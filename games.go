@@ -0,0 +1,92 @@
+package main
+
+import (
+	"regexp"
+)
+
+// Supported Target.Game values. Each selects the status parser matching the output of that game.
+const (
+	gameTF2  = "tf2"
+	gameCSS  = "css"
+	gameCSGO = "csgo"
+	gameCS2  = "cs2"
+	gameL4D2 = "l4d2"
+	gameGMod = "gmod"
+	// gameGoldSrc covers HLDS games such as cs 1.6, which also use the older udp rcon protocol.
+	gameGoldSrc = "goldsrc"
+)
+
+var games = []string{gameTF2, gameCSS, gameCSGO, gameCS2, gameL4D2, gameGMod, gameGoldSrc} //nolint:gochecknoglobals
+
+// newStatusParser returns the status parser for the game. Unknown games use the classic orange box parser.
+func newStatusParser(game string) statusParser {
+	parser := newSourceStatusParser()
+	parser.sections = gameSections(game)
+
+	switch game {
+	case gameCSGO, gameL4D2:
+		// Rows carry an extra slot number after the userid (csgo) and a rate column before the address.
+		parser.rePlayer = regexp.MustCompile(`^#\s*(?P<userid>\d+)\s+(\d+\s+)?"(?P<name>.*?)"\s+(?P<sid>BOT|STEAM_\d:\d:\d+|\[U:\d:\d+])(\s+(?P<time>\d+:\d{2}(:\d{2})?)\s+(?P<ping>\d+)\s+(?P<loss>\d+))?\s+(?P<state>\w+)\s+(?P<rate>\d+)(\s+(?P<ip>\d+\.\d+\.\d+\.\d+:\d+))?\s*$`)
+		parser.reSourceTV = regexp.MustCompile(`^gotv\[\d+]:\s+port\s+(?P<port>\d+),\s+delay\s+(?P<delay>\d+(\.\d+)?)s`)
+	case gameCS2:
+		parser.reMapName = regexp.MustCompile(`^loaded spawngroup\(\s*1\)\s*:\s*SV:\s*\[1:\s*(?P<map_name>\S+)\s*\|`)
+		// CS2 does not include steam ids in the player table. Bots have BOT in place of the connected time.
+		parser.reIgnore = regexp.MustCompile(`^(Server:|Client:|Source TV:|@ Current|source\s*:|spawn\s*:|os\s*:|type\s*:|loaded spawngroup|-+players-+|\s*id\s+time\s+ping|\s*\d+\s+\[NoChan]\s|#end)`)
+		parser.rePlayer = regexp.MustCompile(`^\s*(?P<userid>\d+)\s+(?P<time>\d+:\d{2}(:\d{2})?|BOT)\s+(?P<ping>\d+)\s+(?P<loss>\d+)\s+(?P<state>\w+)\s+(?P<rate>\d+)\s*(?P<ip>\d+\.\d+\.\d+\.\d+:\d+)?\s+'(?P<name>.*)'\s*$`)
+	case gameGoldSrc:
+		// HLDS has no sourcemod, metamod:source or sourcetv, and prints cvars in a different format.
+		parser.command = "status;stats"
+		parser.reAddress = regexp.MustCompile(`^tcp/ip\s*:\s*(?P<addr>\S+)`)
+		// Bots are included in the active count.
+		parser.rePlayers = regexp.MustCompile(`^players\s*:\s+(?P<humans>\d+)\s+active\s+\((?P<max>\d+)\s+max\)`)
+		parser.rePlayer = regexp.MustCompile(`^#\s*(?P<slot>\d+)\s+"(?P<name>.*?)"\s+(?P<userid>\d+)\s+(?P<sid>BOT|STEAM_\d:\d:\d+|VALVE_\d:\d:\d+|STEAM_ID_PENDING|STEAM_ID_LAN)\s+(?P<frags>-?\d+)\s+(?P<time>\d+:\d{2}(:\d{2})?)\s+(?P<ping>\d+)\s+(?P<loss>\d+)(\s+(?P<ip>\d+\.\d+\.\d+\.\d+:\d+))?\s*$`)
+		// The stats columns are CPU, In, Out, Uptime, Users, FPS and Players.
+		parser.reStats = regexp.MustCompile(`^\s*(?P<cpu>\d+\.\d+)\s+(?P<net_in>\d+\.\d+)\s+(?P<net_out>\d+\.\d+)\s+(?P<uptime>\d+)\s+(?P<users>\d+)\s+(?P<fps>\d+\.\d+)\s+(?P<players>\d+)\s*$`)
+	}
+
+	return parser
+}
+
+// gameSections returns the sections expected in the output of the game. A missing section usually means the
+// output format has changed and the parser needs updating.
+func gameSections(game string) []string {
+	switch game {
+	case gameCSGO, gameL4D2:
+		// No edicts line is printed.
+		return []string{sectionMap, sectionPlayers, sectionStats, sectionRate, sectionVersions}
+	case gameCS2, gameGoldSrc:
+		return []string{sectionMap, sectionPlayers, sectionStats}
+	default:
+		return []string{sectionMap, sectionPlayers, sectionEdicts, sectionStats, sectionRate, sectionVersions}
+	}
+}
+
+// newSourceStatusParser returns the parser for the classic orange box status layout used by tf2, css
+// and gmod, which the other games build upon.
+func newSourceStatusParser() statusParser {
+	return statusParser{
+		reHostname:       regexp.MustCompile(`^hostname\s*:\s*(?P<hostname>.*?)\s*$`),
+		reVersion:        regexp.MustCompile(`^version\s*:\s*(?P<version>\S+)\s+(?P<build>\d+)(/\d+)?\s+(?P<secure>secure|insecure)`),
+		reAddress:        regexp.MustCompile(`^udp/ip\s*:\s*(?P<addr>\S+)`),
+		reSteamID:        regexp.MustCompile(`^steam(id)?\s*:\s*(?P<sid>\[[A-Za-z]:\d:\d+(:\d+)?])`),
+		reAccount:        regexp.MustCompile(`^account\s*:\s*(?P<account>.+?)(\s+\(.*\))?\s*$`),
+		reTags:           regexp.MustCompile(`^tags\s*:\s*(?P<tags>.*?)\s*$`),
+		reSourceTV:       regexp.MustCompile(`^sourcetv:\s+(?P<addr>\S+?),\s+delay\s+(?P<delay>\d+(\.\d+)?)s(\s+\(local:\s+(?P<local>\S+?)\))?`),
+		reTVTotal:        regexp.MustCompile(`^Total Slots \d+, Spectators (?P<spectators>\d+), Proxies (?P<proxies>\d+)`),
+		reTVRecording:    regexp.MustCompile(`^Recording to "`),
+		reMMVersion:      regexp.MustCompile(`^\s+Metamod:Source\sversion\s+(?P<mm_version>.+?)$`),
+		reSMVersion:      regexp.MustCompile(`^\s+SourceMod\sVersion:\s(?P<sm_version>.+?)$`),
+		reRate:           regexp.MustCompile(`^"sv_maxupdaterate" = "(?P<rate>\d+)"`),
+		reStats:          regexp.MustCompile(`^(?P<cpu>\d{1,3}\.\d{1,2})\s+(?P<net_in>\d{1,3}\.\d{1,2})\s+(?P<net_out>\d{1,3}\.\d{1,2})\s+(?P<uptime>\d+)\s+(?P<maps>\d+)\s+(?P<fps>\d{1,3}\.\d{1,2})\s+(?P<players>\d+)\s+(?P<connects>\d+)(\s+)?$`),
+		reVisiblePlayers: regexp.MustCompile(`^"sv_visiblemaxplayers" = "(?P<sv_visiblemaxplayers>\d+)"`),
+		reMapName:        regexp.MustCompile(`^map\s+:\s+(?P<map_name>\S+)(\s+at:.*)?$`),
+		reEdicts:         regexp.MustCompile(`^edicts\s+:\s+(?P<edicts>\d+)\sused(\s+of\s+(?P<max>\d+)\s+max)?.*$`),
+		reIgnore: regexp.MustCompile(`^(#\s*userid\s|#\s+name\s|#end|\d+ users|CPU\s|\s+- |` +
+			`\s*(SourceMod|Metamod:Source) Version Information|\s+(SourcePawn|SourceHook|Plugin interface|Loaded As|Compiled on|Built from|Build ID|http)|` +
+			`SourceTV Master|IP \S+, Online|Game Time|Local Slots|Not recording|SourceTV not active|os\s*:|type\s*:)`),
+		command:   "status;stats;sv_maxupdaterate;sm version;meta version;sv_visiblemaxplayers;tv_status",
+		rePlayers: regexp.MustCompile(`^players\s*:\s+(?P<humans>\d+)\s+humans,\s+(?P<bots>\d+)\s+bots\s+\((?P<max>\d+)(/\d+)?\s+max\)`),
+		// Some tf2 builds print the steam id without brackets. Bots and connecting players have no address.
+		rePlayer: regexp.MustCompile(`^#\s*(?P<userid>\d+)\s+"(?P<name>.*?)"\s+(?P<sid>BOT|\[?U:\d:\d+]?|STEAM_\d:\d:\d+)(\s+(?P<time>\d+:\d{2}(:\d{2})?)\s+(?P<ping>\d+)\s+(?P<loss>\d+))?\s+(?P<state>\w+)(\s+(?P<ip>\d+\.\d+\.\d+\.\d+:\d+))?\s*$`),
+	}
+}
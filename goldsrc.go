@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// GoldSrc (HLDS) rcon protocol implementation. Unlike source rcon, commands are sent over udp and each
+// command must carry a challenge previously requested from the server.
+
+const (
+	goldSrcPacketSingle = -1
+	goldSrcPacketSplit  = -2
+
+	goldSrcPrintResponse = 'l'
+	goldSrcMaxPacketSize = 1400
+	goldSrcMaxSplits     = 16
+
+	// goldSrcIdleTimeout is how long to wait for further packets once a response has started. Output
+	// of multiple commands arrives as separate packets with no terminator.
+	goldSrcIdleTimeout = time.Millisecond * 250
+	// goldSrcDrainTimeout is how long to wait for leftover packets before sending a request.
+	goldSrcDrainTimeout = time.Millisecond * 10
+)
+
+var (
+	errGoldSrcChallenge = errors.New("invalid goldsrc challenge response")
+	errGoldSrcPassword  = errors.New("bad rcon password")
+	errGoldSrcHeader    = errors.New("invalid goldsrc packet header")
+	errGoldSrcSplit     = errors.New("invalid goldsrc split packet")
+)
+
+// goldSrcClient is a rcon client for GoldSrc servers. It satisfies the same interface as the source
+// rcon client, so it can be held by rconConn.
+type goldSrcClient struct {
+	conn      net.Conn
+	password  string
+	challenge string
+	timeout   time.Duration
+}
+
+// dialGoldSrc requests a challenge and verifies the password, so that authentication failures are
+// reported when connecting, as they are for source servers. The handshake is bound by ctx as well as
+// the timeout.
+func dialGoldSrc(ctx context.Context, addr string, password string, timeout time.Duration) (*goldSrcClient, error) {
+	dialer := net.Dialer{Timeout: timeout}
+
+	conn, errConn := dialer.DialContext(ctx, "udp", addr)
+	if errConn != nil {
+		return nil, errors.Join(errConn, errDial)
+	}
+
+	client := &goldSrcClient{conn: conn, password: password, timeout: timeout}
+
+	// Closing the connection aborts a blocked read when ctx is cancelled before its deadline.
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+
+	errHandshake := client.handshake(ctx)
+	if !stop() {
+		_ = conn.Close()
+
+		return nil, errors.Join(context.Cause(ctx), errDial)
+	}
+
+	if errHandshake != nil {
+		_ = conn.Close()
+
+		return nil, errHandshake
+	}
+
+	return client, nil
+}
+
+func (c *goldSrcClient) handshake(ctx context.Context) error {
+	if errChallenge := c.requestChallenge(ctx); errChallenge != nil {
+		return errors.Join(errChallenge, errDial)
+	}
+
+	_, errExec := c.exec(ctx, "echo")
+
+	return errExec
+}
+
+func (c *goldSrcClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *goldSrcClient) requestChallenge(ctx context.Context) error {
+	response, errQuery := c.query(ctx, "challenge rcon\n")
+	if errQuery != nil {
+		return errQuery
+	}
+
+	fields := strings.Fields(response)
+	if len(fields) != 3 || fields[0] != "challenge" || fields[1] != "rcon" {
+		return fmt.Errorf("%w: %q", errGoldSrcChallenge, response)
+	}
+
+	c.challenge = fields[2]
+
+	return nil
+}
+
+// Exec runs the command and returns its console output. The challenge is refreshed once if the server
+// rejects it, eg: after a map change or restart.
+func (c *goldSrcClient) Exec(command string) (string, error) {
+	return c.exec(context.Background(), command)
+}
+
+func (c *goldSrcClient) exec(ctx context.Context, command string) (string, error) {
+	for attempt := range 2 {
+		response, errQuery := c.query(ctx, fmt.Sprintf("rcon %s \"%s\" %s\n", c.challenge, c.password, command))
+		if errQuery != nil {
+			return "", errQuery
+		}
+
+		switch {
+		case strings.HasPrefix(response, "Bad rcon_password"):
+			return "", errors.Join(errGoldSrcPassword, errAuth)
+		case strings.HasPrefix(response, "Bad challenge") && attempt == 0:
+			if errChallenge := c.requestChallenge(ctx); errChallenge != nil {
+				return "", errChallenge
+			}
+		default:
+			return response, nil
+		}
+	}
+
+	return "", errGoldSrcChallenge
+}
+
+// query sends a connectionless packet and returns the text of every response packet received until the
+// server stops sending. The deadline is the earlier of the timeout and the deadline of ctx.
+func (c *goldSrcClient) query(ctx context.Context, request string) (string, error) {
+	deadline := time.Now().Add(c.timeout)
+	if ctxDeadline, found := ctx.Deadline(); found && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	if errDrain := c.drain(); errDrain != nil {
+		return "", errDrain
+	}
+
+	if errDeadline := c.conn.SetDeadline(deadline); errDeadline != nil {
+		return "", errDeadline
+	}
+
+	if _, errWrite := c.conn.Write(append([]byte{0xff, 0xff, 0xff, 0xff}, request...)); errWrite != nil {
+		return "", errWrite
+	}
+
+	var (
+		response strings.Builder
+		splits   *goldSrcSplitAssembler
+		buf      = make([]byte, goldSrcMaxPacketSize*2)
+	)
+
+	for {
+		size, errRead := c.conn.Read(buf)
+		if errRead != nil {
+			var netErr net.Error
+			if response.Len() > 0 && errors.As(errRead, &netErr) && netErr.Timeout() {
+				return response.String(), nil
+			}
+
+			return "", errRead
+		}
+
+		payload, complete, errPacket := readGoldSrcPacket(buf[:size], &splits)
+		if errPacket != nil {
+			return "", errors.Join(errPacket, errParse)
+		}
+
+		if !complete {
+			continue
+		}
+
+		response.WriteString(payload)
+
+		idle := time.Now().Add(goldSrcIdleTimeout)
+		if idle.After(deadline) {
+			idle = deadline
+		}
+
+		if errDeadline := c.conn.SetReadDeadline(idle); errDeadline != nil {
+			return "", errDeadline
+		}
+	}
+}
+
+// drain discards packets left over from an earlier response, eg: those arriving after the idle timeout ended
+// the read, so they are not returned as the start of the next response.
+func (c *goldSrcClient) drain() error {
+	if errDeadline := c.conn.SetReadDeadline(time.Now().Add(goldSrcDrainTimeout)); errDeadline != nil {
+		return errDeadline
+	}
+
+	buf := make([]byte, goldSrcMaxPacketSize*2)
+
+	for {
+		if _, errRead := c.conn.Read(buf); errRead != nil {
+			var netErr net.Error
+			if errors.As(errRead, &netErr) && netErr.Timeout() {
+				return nil
+			}
+
+			return errRead
+		}
+	}
+}
+
+// readGoldSrcPacket decodes a response packet, returning false until every part of a split response has
+// been received.
+func readGoldSrcPacket(packet []byte, splits **goldSrcSplitAssembler) (string, bool, error) {
+	if len(packet) < 4 {
+		return "", false, errGoldSrcHeader
+	}
+
+	switch int32(binary.LittleEndian.Uint32(packet)) { //nolint:gosec
+	case goldSrcPacketSingle:
+		return parseGoldSrcPayload(packet[4:]), true, nil
+	case goldSrcPacketSplit:
+		if *splits == nil {
+			*splits = &goldSrcSplitAssembler{}
+		}
+
+		payload, complete, errSplit := (*splits).add(packet[4:])
+		if errSplit != nil || !complete {
+			return "", false, errSplit
+		}
+
+		*splits = nil
+
+		if len(payload) < 4 || int32(binary.LittleEndian.Uint32(payload)) != goldSrcPacketSingle { //nolint:gosec
+			return "", false, errGoldSrcHeader
+		}
+
+		return parseGoldSrcPayload(payload[4:]), true, nil
+	default:
+		return "", false, errGoldSrcHeader
+	}
+}
+
+// parseGoldSrcPayload strips the response type and trailing null bytes.
+func parseGoldSrcPayload(payload []byte) string {
+	payload = bytes.TrimRight(payload, "\x00")
+	if len(payload) > 0 && payload[0] == goldSrcPrintResponse {
+		payload = payload[1:]
+	}
+
+	return string(payload)
+}
+
+// goldSrcSplitAssembler collects the fragments of a split response. GoldSrc split packets use a single
+// byte holding the packet number in the upper and the total in the lower nibble.
+type goldSrcSplitAssembler struct {
+	id    uint32
+	total int
+	parts map[int][]byte
+}
+
+func (a *goldSrcSplitAssembler) add(packet []byte) ([]byte, bool, error) {
+	if len(packet) < 5 {
+		return nil, false, errGoldSrcSplit
+	}
+
+	id := binary.LittleEndian.Uint32(packet)
+	total := int(packet[4] & 0x0f)
+	number := int(packet[4] >> 4)
+
+	if total == 0 || total > goldSrcMaxSplits || number >= total {
+		return nil, false, errGoldSrcSplit
+	}
+
+	if a.parts == nil {
+		a.id = id
+		a.total = total
+		a.parts = map[int][]byte{}
+	} else if a.id != id || a.total != total {
+		return nil, false, errGoldSrcSplit
+	}
+
+	a.parts[number] = bytes.Clone(packet[5:])
+
+	if len(a.parts) < a.total {
+		return nil, false, nil
+	}
+
+	var payload []byte
+	for idx := range a.total {
+		payload = append(payload, a.parts[idx]...)
+	}
+
+	return payload, true, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// startGoldSrcTestServer answers rcon requests like HLDS, returning the status output split over two
+// packets and a second packet for the stats command.
+func startGoldSrcTestServer(t *testing.T, password string) string {
+	t.Helper()
+
+	conn, errListen := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, errListen)
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	const challenge = "1234567890"
+
+	reply := func(addr net.Addr, text string) {
+		_, _ = conn.WriteTo(append([]byte{0xff, 0xff, 0xff, 0xff, 'l'}, text+"\x00\x00"...), addr)
+	}
+
+	go func() {
+		buf := make([]byte, goldSrcMaxPacketSize)
+
+		for {
+			size, addr, errRead := conn.ReadFrom(buf)
+			if errRead != nil {
+				return
+			}
+
+			request := strings.TrimSpace(string(buf[4:size]))
+
+			switch {
+			case request == "challenge rcon":
+				_, _ = conn.WriteTo([]byte("\xff\xff\xff\xffchallenge rcon "+challenge+"\n\x00"), addr)
+			case !strings.HasPrefix(request, "rcon "+challenge+" "):
+				reply(addr, "Bad challenge.\n")
+			case !strings.HasPrefix(request, fmt.Sprintf("rcon %s \"%s\" ", challenge, password)):
+				reply(addr, "Bad rcon_password.\n")
+			case strings.HasSuffix(request, " echo"):
+				reply(addr, "\n")
+			default:
+				payload := append([]byte{0xff, 0xff, 0xff, 0xff, 'l'}, "hostname:  Test Server\nmap     :  de_dust2 at: 0 x, 0 y, 0 z\n"...)
+				half := len(payload) / 2
+
+				for idx, part := range [][]byte{payload[:half], payload[half:]} {
+					header := binary.LittleEndian.AppendUint32([]byte{0xfe, 0xff, 0xff, 0xff}, 7)
+					_, _ = conn.WriteTo(append(append(header, byte(idx<<4|2)), part...), addr)
+				}
+
+				reply(addr, "CPU   In    Out   Uptime  Users   FPS    Players\n 1.00  2.00  3.00      10     0  500.00       0\n")
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestGoldSrcExec(t *testing.T) {
+	addr := startGoldSrcTestServer(t, "secret")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	client, errDial := dialGoldSrc(ctx, addr, "secret", time.Second)
+	require.NoError(t, errDial)
+
+	defer func() {
+		_ = client.Close()
+	}()
+
+	parser := newStatusParser(gameGoldSrc)
+	require.Equal(t, "status;stats", parser.command)
+
+	body, errExec := client.Exec(parser.command)
+	require.NoError(t, errExec)
+
+	result, errParse := parser.parse(body)
+	require.NoError(t, errParse)
+	require.Zero(t, result.UnmatchedLines)
+	require.Equal(t, "Test Server", result.Hostname)
+	require.Equal(t, "de_dust2", result.Map)
+	require.InDelta(t, 500.0, result.FPS, 0.001)
+	require.Equal(t, 10, result.Uptime)
+
+	_, errAuthFail := dialGoldSrc(ctx, addr, "wrong", time.Second)
+	require.ErrorIs(t, errAuthFail, errAuth)
+	require.Equal(t, errClassAuth, classifyError(errAuthFail))
+}
+
+func TestGoldSrcDialContext(t *testing.T) {
+	// A server which never replies, leaving the handshake blocked until the context ends.
+	conn, errListen := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, errListen)
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	start := time.Now()
+	_, errTimeout := dialGoldSrc(ctx, conn.LocalAddr().String(), "secret", time.Minute)
+	require.ErrorIs(t, errTimeout, errDial)
+	require.Less(t, time.Since(start), time.Second*5)
+
+	cancelCtx, cancelDial := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond*100, cancelDial)
+
+	start = time.Now()
+	_, errCancel := dialGoldSrc(cancelCtx, conn.LocalAddr().String(), "secret", time.Minute)
+	require.ErrorIs(t, errCancel, context.Canceled)
+	require.Less(t, time.Since(start), time.Second*5)
+}
+
+func TestGoldSrcQueryDrainsLatePackets(t *testing.T) {
+	conn, errListen := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, errListen)
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	reply := func(addr net.Addr, text string) {
+		_, _ = conn.WriteTo(append([]byte{0xff, 0xff, 0xff, 0xff, 'l'}, text+"\x00\x00"...), addr)
+	}
+
+	go func() {
+		buf := make([]byte, goldSrcMaxPacketSize)
+
+		for {
+			size, addr, errRead := conn.ReadFrom(buf)
+			if errRead != nil {
+				return
+			}
+
+			request := strings.TrimSpace(string(buf[4:size]))
+			reply(addr, request+"\n")
+
+			// The first response has a straggler arriving after the client stopped reading.
+			if request == "first" {
+				time.AfterFunc(goldSrcIdleTimeout*2, func() { reply(addr, "late\n") })
+			}
+		}
+	}()
+
+	client, errDial := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, errDial)
+
+	goldSrc := &goldSrcClient{conn: client, timeout: time.Second}
+
+	defer func() {
+		_ = goldSrc.Close()
+	}()
+
+	first, errFirst := goldSrc.query(context.Background(), "first\n")
+	require.NoError(t, errFirst)
+	require.Equal(t, "first\n", first)
+
+	time.Sleep(goldSrcIdleTimeout * 2)
+
+	second, errSecond := goldSrc.query(context.Background(), "second\n")
+	require.NoError(t, errSecond)
+	require.Equal(t, "second\n", second)
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	logPacketPlain  = 'R'
+	logPacketSecret = 'S'
+
+	logMaxPacketSize   = 4096
+	logResolveInterval = time.Minute * 5
+
+	// The event and weapon labels come from unauthenticated packets, so the number of distinct values kept
+	// per target is capped. Events with new values over the cap are counted as unmatched.
+	logMaxEvents  = 64
+	logMaxWeapons = 256
+)
+
+var (
+	errLogHeader = errors.New("invalid log packet header")
+	errLogFormat = errors.New("invalid log packet format")
+)
+
+// logPacket is a single parsed srcds logaddress packet.
+type logPacket struct {
+	secret string
+	line   string
+}
+
+// parseLogPacket decodes the udp packets sent by srcds to addresses registered with logaddress_add. When
+// sv_logsecret is set the packet type is S and the secret immediately precedes the log line.
+func parseLogPacket(data []byte) (logPacket, error) {
+	if len(data) < 5 || !bytes.Equal(data[:4], []byte{0xff, 0xff, 0xff, 0xff}) {
+		return logPacket{}, errLogHeader
+	}
+
+	var packet logPacket
+
+	body := data[5:]
+
+	switch data[4] {
+	case logPacketPlain:
+	case logPacketSecret:
+		idx := bytes.Index(body, []byte("L "))
+		if idx < 0 {
+			return logPacket{}, errLogFormat
+		}
+
+		packet.secret = string(body[:idx])
+		body = body[idx:]
+	default:
+		return logPacket{}, errLogHeader
+	}
+
+	if !bytes.HasPrefix(body, []byte("L ")) {
+		return logPacket{}, errLogFormat
+	}
+
+	packet.line = strings.TrimRight(string(body), "\x00\r\n")
+
+	return packet, nil
+}
+
+// logEvent is a game event parsed from a single log line.
+type logEvent struct {
+	name   string
+	weapon string
+}
+
+type logParser struct {
+	reKill       *regexp.Regexp
+	reSuicide    *regexp.Regexp
+	reSay        *regexp.Regexp
+	reConnected  *regexp.Regexp
+	reDisconnect *regexp.Regexp
+	reEntered    *regexp.Regexp
+	reTriggered  *regexp.Regexp
+	reMapLoad    *regexp.Regexp
+	reMapStart   *regexp.Regexp
+}
+
+func newLogParser() logParser {
+	const player = `"(?P<name>.*?)<\d+><(?P<sid>.*?)><(?P<team>.*?)>"`
+
+	return logParser{
+		reKill:       regexp.MustCompile(`^` + player + ` killed "(?P<victim>.*?)<\d+><.*?><.*?>" with "(?P<weapon>.*?)"`),
+		reSuicide:    regexp.MustCompile(`^` + player + ` committed suicide with "(?P<weapon>.*?)"`),
+		reSay:        regexp.MustCompile(`^` + player + ` (?P<type>say|say_team) "`),
+		reConnected:  regexp.MustCompile(`^` + player + ` connected, address "`),
+		reDisconnect: regexp.MustCompile(`^` + player + ` disconnected`),
+		reEntered:    regexp.MustCompile(`^` + player + ` entered the game`),
+		reTriggered:  regexp.MustCompile(`^World triggered "(?P<event>\w+)"`),
+		reMapLoad:    regexp.MustCompile(`^Loading map "`),
+		reMapStart:   regexp.MustCompile(`^Started map "`),
+	}
+}
+
+// parse converts a log line into an event. Lines that are not of interest return false.
+func (p logParser) parse(line string) (logEvent, bool) {
+	// Strip the "L 10/17/2026 - 12:00:00: " prefix.
+	idx := strings.Index(line, ": ")
+	if !strings.HasPrefix(line, "L ") || idx < 0 {
+		return logEvent{}, false
+	}
+
+	message := line[idx+2:]
+
+	if match := p.reKill.FindStringSubmatch(message); match != nil {
+		return logEvent{name: "kill", weapon: group(p.reKill, match, "weapon")}, true
+	}
+
+	if match := p.reSuicide.FindStringSubmatch(message); match != nil {
+		return logEvent{name: "suicide", weapon: group(p.reSuicide, match, "weapon")}, true
+	}
+
+	if match := p.reSay.FindStringSubmatch(message); match != nil {
+		return logEvent{name: group(p.reSay, match, "type")}, true
+	}
+
+	if p.reConnected.MatchString(message) {
+		return logEvent{name: "connected"}, true
+	}
+
+	if p.reDisconnect.MatchString(message) {
+		return logEvent{name: "disconnected"}, true
+	}
+
+	if p.reEntered.MatchString(message) {
+		return logEvent{name: "entered"}, true
+	}
+
+	if match := p.reTriggered.FindStringSubmatch(message); match != nil {
+		return logEvent{name: strings.ToLower(group(p.reTriggered, match, "event"))}, true
+	}
+
+	if p.reMapLoad.MatchString(message) {
+		return logEvent{name: "map_load"}, true
+	}
+
+	if p.reMapStart.MatchString(message) {
+		return logEvent{name: "map_start"}, true
+	}
+
+	return logEvent{}, false
+}
+
+// logCounts holds the event counters for a single target.
+type logCounts struct {
+	events map[string]float64
+	kills  map[string]float64
+}
+
+// logCollector listens for srcds log packets and counts the game events found within them. Packets are
+// attributed to a target by their log secret when one is configured, otherwise by their source address.
+type logCollector struct {
+	config *config
+	parser logParser
+
+	mu        sync.RWMutex
+	counts    map[string]*logCounts
+	addrs     map[string]string
+	unmatched float64
+}
+
+func newLogCollector(config *config) *logCollector {
+	return &logCollector{
+		config: config,
+		parser: newLogParser(),
+		counts: map[string]*logCounts{},
+		addrs:  map[string]string{},
+	}
+}
+
+func (c *logCollector) Name() string {
+	return "logs"
+}
+
+// start binds the configured udp address and processes packets until the context is cancelled.
+func (c *logCollector) start(ctx context.Context) error {
+	listenConfig := net.ListenConfig{}
+
+	conn, errListen := listenConfig.ListenPacket(ctx, "udp", c.config.LogListenAddr)
+	if errListen != nil {
+		return errors.Join(errListen, errLogListen)
+	}
+
+	slog.Info("Listening for logs", slog.String("addr", conn.LocalAddr().String()))
+
+	go runEvery(ctx, logResolveInterval, c.resolve)
+
+	go func() {
+		<-ctx.Done()
+
+		if errClose := conn.Close(); errClose != nil {
+			slog.Error("Failed to close log listener", slog.String("error", errClose.Error()))
+		}
+	}()
+
+	go c.listen(conn)
+
+	return nil
+}
+
+func (c *logCollector) listen(conn net.PacketConn) {
+	buf := make([]byte, logMaxPacketSize)
+
+	for {
+		size, addr, errRead := conn.ReadFrom(buf)
+		if errRead != nil {
+			if !errors.Is(errRead, net.ErrClosed) {
+				slog.Error("Failed to read log packet", slog.String("error", errRead.Error()))
+			}
+
+			return
+		}
+
+		c.handle(addr, buf[:size])
+	}
+}
+
+// reload replaces the config and refreshes the address mapping. Counters of removed targets are dropped.
+// Changes to the listen address require a restart.
+func (c *logCollector) reload(ctx context.Context, config *config) {
+	c.mu.Lock()
+
+	c.config = config
+
+	for name := range c.counts {
+		if !slices.ContainsFunc(config.Targets, func(target Target) bool { return target.Name == name }) {
+			delete(c.counts, name)
+		}
+	}
+
+	c.mu.Unlock()
+
+	c.resolve(ctx)
+}
+
+// resolve refreshes the mapping of source addresses to target names.
+func (c *logCollector) resolve(ctx context.Context) {
+	addrs := map[string]string{}
+
+	c.mu.RLock()
+	targets := c.config.Targets
+	c.mu.RUnlock()
+
+	for _, target := range targets {
+		ipAddrs, errLookup := net.DefaultResolver.LookupIPAddr(ctx, target.Host)
+		if errLookup != nil {
+			slog.Warn("Failed to resolve target host", slog.String("server", target.Name), slog.String("error", errLookup.Error()))
+
+			continue
+		}
+
+		for _, ipAddr := range ipAddrs {
+			addrs[net.JoinHostPort(ipAddr.IP.String(), strconv.Itoa(int(target.Port)))] = target.Name
+		}
+	}
+
+	c.mu.Lock()
+	c.addrs = addrs
+	c.mu.Unlock()
+}
+
+// match finds the target name responsible for a packet. Targets with a log secret only accept
+// packets carrying that secret.
+func (c *logCollector) match(addr net.Addr, packet logPacket) (string, bool) {
+	if packet.secret != "" {
+		for _, target := range c.config.Targets {
+			if target.LogSecret == packet.secret {
+				return target.Name, true
+			}
+		}
+
+		return "", false
+	}
+
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return "", false
+	}
+
+	exact, found := c.addrs[udpAddr.String()]
+	if !found {
+		// srcds may send from an ephemeral port, fall back to the ip when it is unambiguous.
+		for targetAddr, name := range c.addrs {
+			host, _, _ := net.SplitHostPort(targetAddr)
+			if host != udpAddr.IP.String() {
+				continue
+			}
+
+			if found && exact != name {
+				return "", false
+			}
+
+			exact, found = name, true
+		}
+	}
+
+	if !found {
+		return "", false
+	}
+
+	for _, target := range c.config.Targets {
+		if target.Name == exact {
+			return exact, target.LogSecret == ""
+		}
+	}
+
+	return "", false
+}
+
+func (c *logCollector) handle(addr net.Addr, data []byte) {
+	packet, errPacket := parseLogPacket(data)
+	if errPacket != nil {
+		slog.Debug("Invalid log packet", slog.String("addr", addr.String()), slog.String("error", errPacket.Error()))
+
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name, found := c.match(addr, packet)
+	if !found {
+		c.unmatched++
+
+		return
+	}
+
+	event, ok := c.parser.parse(packet.line)
+	if !ok {
+		return
+	}
+
+	counts, found := c.counts[name]
+	if !found {
+		counts = &logCounts{events: map[string]float64{}, kills: map[string]float64{}}
+		c.counts[name] = counts
+	}
+
+	_, knownEvent := counts.events[event.name]
+	_, knownWeapon := counts.kills[event.weapon]
+
+	// Packets are unauthenticated, an invalid label value would make every following scrape panic. Real event
+	// and weapon names are always valid utf-8.
+	if !utf8.ValidString(event.name) || !utf8.ValidString(event.weapon) ||
+		(!knownEvent && len(counts.events) >= logMaxEvents) ||
+		(event.name == "kill" && !knownWeapon && len(counts.kills) >= logMaxWeapons) {
+		c.unmatched++
+
+		return
+	}
+
+	counts.events[event.name]++
+
+	if event.name == "kill" {
+		counts.kills[event.weapon]++
+	}
+}
+
+func createLogDesc(namespace string, stat string, labels prometheus.Labels) *prometheus.Desc {
+	switch stat {
+	case "events_total":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", stat),
+			"The total number of game events received via logaddress",
+			nil, labels)
+	case "player_kills_total":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", stat),
+			"The total number of player kills received via logaddress",
+			nil, labels)
+	case "log_unmatched_packets_total":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", stat),
+			"The total number of log packets that could not be attributed to a target, carried an invalid weapon name, or a new event or weapon once the limit was reached",
+			nil, labels)
+	default:
+		slog.Warn("Unhandled stat Name", slog.String("stat", stat))
+	}
+
+	return nil
+}
+
+func (c *logCollector) Update(_ context.Context, metricCHan chan<- prometheus.Metric) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, server := range c.config.Targets {
+		counts, found := c.counts[server.Name]
+		if !found {
+			continue
+		}
+
+		labels := c.config.targetLabels(server)
+
+		for event, value := range counts.events {
+			desc := createLogDesc(c.config.NameSpace, "events_total", mergeLabels(labels, prometheus.Labels{"event": event}))
+			metricCHan <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value)
+		}
+
+		for weapon, value := range counts.kills {
+			desc := createLogDesc(c.config.NameSpace, "player_kills_total", mergeLabels(labels, prometheus.Labels{"weapon": weapon}))
+			metricCHan <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value)
+		}
+	}
+
+	unmatched := createLogDesc(c.config.NameSpace, "log_unmatched_packets_total", c.config.Labels)
+	metricCHan <- prometheus.MustNewConstMetric(unmatched, prometheus.CounterValue, c.unmatched)
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLogPacket(t *testing.T) {
+	plain, errPlain := parseLogPacket([]byte("\xff\xff\xff\xffRL 10/17/2026 - 12:00:00: Started map \"pl_upward\"\n\x00"))
+	require.NoError(t, errPlain)
+	require.Equal(t, logPacket{line: `L 10/17/2026 - 12:00:00: Started map "pl_upward"`}, plain)
+
+	secret, errSecret := parseLogPacket([]byte("\xff\xff\xff\xffS1234567L 10/17/2026 - 12:00:00: Started map \"pl_upward\"\n\x00"))
+	require.NoError(t, errSecret)
+	require.Equal(t, logPacket{secret: "1234567", line: `L 10/17/2026 - 12:00:00: Started map "pl_upward"`}, secret)
+
+	_, errHeader := parseLogPacket([]byte("RL 10/17/2026 - 12:00:00: Started map"))
+	require.ErrorIs(t, errHeader, errLogHeader)
+}
+
+func TestLogParser(t *testing.T) {
+	parser := newLogParser()
+
+	for line, expected := range map[string]logEvent{
+		`L 10/17/2026 - 12:00:00: "Dred<774><[U:1:102426391]><Red>" killed "smiley<775><[U:1:279850548]><Blue>" with "scattergun" (attacker_position "1 2 3")`: {name: "kill", weapon: "scattergun"},
+		`L 10/17/2026 - 12:00:00: "Dred<774><[U:1:102426391]><Red>" committed suicide with "world" (attacker_position "1 2 3")`:                                {name: "suicide", weapon: "world"},
+		`L 10/17/2026 - 12:00:00: "Dred<774><[U:1:102426391]><Red>" say "hello"`:                                                                               {name: "say"},
+		`L 10/17/2026 - 12:00:00: "Dred<774><[U:1:102426391]><Red>" say_team "hello"`:                                                                          {name: "say_team"},
+		`L 10/17/2026 - 12:00:00: "Dred<774><[U:1:102426391]><>" connected, address "10.0.0.1:27005"`:                                                          {name: "connected"},
+		`L 10/17/2026 - 12:00:00: "Dred<774><[U:1:102426391]><Red>" disconnected (reason "Disconnect by user.")`:                                               {name: "disconnected"},
+		`L 10/17/2026 - 12:00:00: "Dred<774><[U:1:102426391]><Unassigned>" entered the game`:                                                                   {name: "entered"},
+		`L 10/17/2026 - 12:00:00: World triggered "Round_Win" (winner "Red")`:                                                                                  {name: "round_win"},
+		`L 10/17/2026 - 12:00:00: Loading map "pl_upward"`:                                                                                                     {name: "map_load"},
+	} {
+		event, ok := parser.parse(line)
+		require.True(t, ok, line)
+		require.Equal(t, expected, event, line)
+	}
+
+	_, ok := parser.parse(`L 10/17/2026 - 12:00:00: server_cvar: "sv_tags" "payload"`)
+	require.False(t, ok)
+}
+
+func TestLogCollectorAttribution(t *testing.T) {
+	conf := newConfig()
+	conf.Targets = []Target{
+		{Name: "plain", Host: "10.0.0.1", Port: 27015},
+		{Name: "secret", Host: "10.0.0.2", Port: 27015, LogSecret: "1234"},
+	}
+
+	collector := newLogCollector(conf)
+	collector.addrs = map[string]string{"10.0.0.1:27015": "plain", "10.0.0.2:27015": "secret"}
+
+	kill := "L 10/17/2026 - 12:00:00: \"a<1><[U:1:1]><Red>\" killed \"b<2><[U:1:2]><Blue>\" with \"minigun\"\n\x00"
+
+	collector.handle(&net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 27015}, []byte("\xff\xff\xff\xffR"+kill))
+	collector.handle(&net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 40000}, []byte("\xff\xff\xff\xffR"+kill))
+	// Targets with a secret reject plain packets, even from the expected address.
+	collector.handle(&net.UDPAddr{IP: net.ParseIP("10.0.0.2"), Port: 27015}, []byte("\xff\xff\xff\xffR"+kill))
+	collector.handle(&net.UDPAddr{IP: net.ParseIP("192.168.0.1"), Port: 27015}, []byte("\xff\xff\xff\xffS1234"+kill))
+	collector.handle(&net.UDPAddr{IP: net.ParseIP("192.168.0.1"), Port: 27015}, []byte("\xff\xff\xff\xffR"+kill))
+
+	require.InDelta(t, 2, collector.counts["plain"].kills["minigun"], 0)
+	require.InDelta(t, 1, collector.counts["secret"].events["kill"], 0)
+	require.InDelta(t, 2, collector.unmatched, 0)
+}
+
+func TestLogCollectorLabelLimit(t *testing.T) {
+	conf := newConfig()
+	conf.Targets = []Target{{Name: "plain", Host: "10.0.0.1", Port: 27015}}
+
+	collector := newLogCollector(conf)
+	collector.addrs = map[string]string{"10.0.0.1:27015": "plain"}
+	addr := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 27015}
+
+	for idx := range logMaxWeapons + 10 {
+		collector.handle(addr, []byte(fmt.Sprintf("\xff\xff\xff\xffRL 10/17/2026 - 12:00:00: \"a<1><[U:1:1]><Red>\" killed \"b<2><[U:1:2]><Blue>\" with \"weapon%d\"\n", idx)))
+	}
+
+	for idx := range logMaxEvents + 10 {
+		collector.handle(addr, []byte(fmt.Sprintf("\xff\xff\xff\xffRL 10/17/2026 - 12:00:00: World triggered \"Event%d\"\n", idx)))
+	}
+
+	// Known values are still counted once the limit is reached
+	collector.handle(addr, []byte("\xff\xff\xff\xffRL 10/17/2026 - 12:00:00: \"a<1><[U:1:1]><Red>\" killed \"b<2><[U:1:2]><Blue>\" with \"weapon0\"\n"))
+
+	require.Len(t, collector.counts["plain"].kills, logMaxWeapons)
+	require.Len(t, collector.counts["plain"].events, logMaxEvents)
+	require.InDelta(t, 2, collector.counts["plain"].kills["weapon0"], 0)
+	// kill takes one of the event slots
+	require.InDelta(t, 10+11, collector.unmatched, 0)
+}
+
+func TestLogCollectorInvalidUTF8(t *testing.T) {
+	conf := newConfig()
+	conf.Targets = []Target{{Name: "plain", Host: "10.0.0.1", Port: 27015}}
+
+	collector := newLogCollector(conf)
+	collector.addrs = map[string]string{"10.0.0.1:27015": "plain"}
+	addr := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 27015}
+
+	collector.handle(addr, []byte("\xff\xff\xff\xffRL 10/17/2026 - 12:00:00: \"a<1><[U:1:1]><Red>\" killed \"b<2><[U:1:2]><Blue>\" with \"sc\xffgun\"\n"))
+	collector.handle(addr, []byte("\xff\xff\xff\xffRL 10/17/2026 - 12:00:00: \"a<1><[U:1:1]><Red>\" committed suicide with \"sc\xc3\"\n"))
+
+	require.InDelta(t, 2, collector.unmatched, 0)
+	require.NotContains(t, scrape(t, collector), "srcds_player_kills_total{")
+}
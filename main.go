@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -53,36 +55,49 @@ func mustCreateLogger(levelString string) func() {
 	return closer
 }
 
-func run() int {
-	ctx := context.Background()
-	build := versionInfo{version: version, commit: commit, date: date, builtBy: builtBy}
-
-	signalCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
-	defer stop()
+// checkConfig implements the check-config subcommand, which validates the config and exits non-zero
+// listing every problem found. Secrets are not resolved, since they are usually only present in the
+// deployment.
+func checkConfig(args []string) int {
+	flags := flag.NewFlagSet("check-config", flag.ContinueOnError)
+	configPath := flags.String("config", defaultConfigPath, "Path to the config file")
 
-	conf := newConfig()
+	if errParse := flags.Parse(args); errParse != nil {
+		return 2
+	}
 
-	if !exists(defaultConfigPath) {
-		slog.Error("Failed to find config file", slog.String("config_path", defaultConfigPath))
+	conf, errConfig := parseConfigFile(*configPath, true)
+	if errConfig != nil {
+		fmt.Fprintf(os.Stderr, "%s: invalid config:\n%s\n", *configPath, errConfig.Error())
 
 		return 1
 	}
 
-	configFile, cfErr := os.Open(defaultConfigPath)
-	if cfErr != nil {
-		slog.Error("Failed to open config file", slog.String("error", cfErr.Error()))
+	fmt.Printf("%s: ok, %d targets\n", *configPath, len(conf.Targets)) //nolint:forbidigo
 
-		return 1
+	return 0
+}
+
+func run() int {
+	if len(os.Args) > 1 && os.Args[1] == "check-config" {
+		return checkConfig(os.Args[2:])
 	}
 
-	if errRead := conf.read(configFile); errRead != nil {
-		slog.Error("Failed to read config file", slog.String("error", errRead.Error()))
+	ctx := context.Background()
+	build := versionInfo{version: version, commit: commit, date: date, builtBy: builtBy}
 
-		return 1
-	}
+	configPath := flag.String("config", defaultConfigPath, "Path to the config file")
+	flag.Parse()
+
+	signalCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Once a config file has been found, it must continue to exist for future reloads.
+	configRequired := isFlagSet("config") || exists(*configPath)
 
-	if errClose := configFile.Close(); errClose != nil {
-		slog.Error("Failed to close config file after read", slog.String("error", errClose.Error()))
+	conf, errConfig := readConfigFile(*configPath, configRequired)
+	if errConfig != nil {
+		slog.Error("Failed to read config file", slog.String("config_path", *configPath), slog.String("error", errConfig.Error()))
 
 		return 1
 	}
@@ -90,14 +105,14 @@ func run() int {
 	closeLog := mustCreateLogger(conf.LogLevel)
 	defer closeLog()
 
-	slog.Debug("Using config file", slog.String("config_path", defaultConfigPath))
+	slog.Debug("Using config file", slog.String("config_path", *configPath))
 
 	slog.Info("Starting srcds_watch",
 		slog.String("version", build.version),
 		slog.String("commit", build.commit),
 		slog.String("date", build.date))
 
-	if errApp := start(signalCtx, conf); errApp != nil {
+	if errApp := start(signalCtx, conf, *configPath, configRequired); errApp != nil {
 		slog.Error("Application returned error", slog.String("error", errApp.Error()))
 
 		return 1
@@ -108,6 +123,18 @@ func run() int {
 	return 0
 }
 
+func isFlagSet(name string) bool {
+	found := false
+
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+
+	return found
+}
+
 func main() {
 	os.Exit(run())
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"maps"
+	"time"
+)
+
+// mapHistory tracks the map rotation of a server, derived from successive statuses. Like playerChurn,
+// values are replaced rather than modified.
+type mapHistory struct {
+	current string
+	// since is when the current map was first seen, or the first poll if the exporter started mid map.
+	since   time.Time
+	changes uint64
+	// edictsPeak is the highest edict usage seen on the current map.
+	edictsPeak int
+	// playerSeconds accumulates the number of human players multiplied by the time spent on each map.
+	playerSeconds map[string]float64
+}
+
+// observe returns the history updated with the current status. The time between two consecutive successful
+// polls is credited to the map and human player count of the earlier poll, time spent offline is not counted.
+func (h mapHistory) observe(previous *status, previousSeen time.Time, current *status, now time.Time) mapHistory {
+	next := h
+	next.playerSeconds = maps.Clone(h.playerSeconds)
+
+	if next.playerSeconds == nil {
+		next.playerSeconds = map[string]float64{}
+	}
+
+	if previous != nil {
+		next.playerSeconds[previous.Map] += float64(previous.PlayersHumans) * now.Sub(previousSeen).Seconds()
+	}
+
+	if current.Map != h.current {
+		if h.current != "" {
+			next.changes++
+		}
+
+		next.current = current.Map
+		next.since = now
+		next.edictsPeak = 0
+	}
+
+	next.edictsPeak = max(next.edictsPeak, current.Edicts)
+
+	return next
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapHistory(t *testing.T) {
+	cache := newStatusCache()
+	now := time.Now()
+
+	cache.update("a", &status{Map: "pl_upward", PlayersHumans: 10}, nil, now)
+	cache.update("a", &status{Map: "pl_upward", PlayersHumans: 20}, nil, now.Add(time.Minute))
+	cache.update("a", &status{Map: "cp_badlands", PlayersHumans: 4}, nil, now.Add(time.Minute*2))
+
+	snap, _ := cache.get("a")
+	require.Equal(t, "cp_badlands", snap.maps.current)
+	require.Equal(t, now.Add(time.Minute*2), snap.maps.since)
+	require.Equal(t, uint64(1), snap.maps.changes)
+	require.InDelta(t, 1800, snap.maps.playerSeconds["pl_upward"], 0.001)
+
+	// Time spent offline is not credited to the map
+	cache.update("a", nil, errors.Join(errors.New("refused"), errDial), now.Add(time.Minute*3))
+	cache.update("a", &status{Map: "cp_badlands", PlayersHumans: 4}, nil, now.Add(time.Hour))
+
+	snap, _ = cache.get("a")
+	require.Equal(t, uint64(1), snap.maps.changes)
+	require.Equal(t, now.Add(time.Minute*2), snap.maps.since)
+	require.Zero(t, snap.maps.playerSeconds["cp_badlands"])
+
+	cache.update("a", &status{Map: "cp_badlands", PlayersHumans: 4}, nil, now.Add(time.Hour+time.Minute))
+
+	snap, _ = cache.get("a")
+	require.InDelta(t, 240, snap.maps.playerSeconds["cp_badlands"], 0.001)
+
+	conf := newConfig()
+	conf.Targets = []Target{{Name: "a", Protocol: protocolRCON, Game: gameTF2}}
+
+	text := scrape(t, newStatusCollector(conf, cache))
+	require.Contains(t, text, `srcds_map_info{map="cp_badlands",server="a"} 1`)
+	require.Contains(t, text, `srcds_map_changes_total{server="a"} 1`)
+	require.Contains(t, text, `srcds_map_player_seconds_total{map="pl_upward",server="a"} 1800`)
+	require.Contains(t, text, `srcds_map_current_seconds{server="a"}`)
+}
+
+func TestMapHistoryEdictsPeak(t *testing.T) {
+	now := time.Now()
+
+	history := mapHistory{}.observe(nil, now, &status{Map: "pl_upward", Edicts: 1200}, now)
+	history = history.observe(nil, now, &status{Map: "pl_upward", Edicts: 1900}, now.Add(time.Minute))
+	history = history.observe(nil, now, &status{Map: "pl_upward", Edicts: 1400}, now.Add(time.Minute*2))
+	require.Equal(t, 1900, history.edictsPeak)
+
+	// The peak is reset on map change
+	history = history.observe(nil, now, &status{Map: "cp_badlands", Edicts: 800}, now.Add(time.Minute*3))
+	require.Equal(t, 800, history.edictsPeak)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Supported PlayerConfig.Labels values.
+const (
+	playerLabelUserID = "userid"
+	playerLabelName   = "name"
+	playerLabelIP     = "ip"
+	playerLabelPort   = "port"
+)
+
+var playerLabels = []string{playerLabelUserID, playerLabelName, playerLabelIP, playerLabelPort} //nolint:gochecknoglobals
+
+// Supported PlayerConfig.IPMode values.
+const (
+	ipModeHash     = "hash"
+	ipModeTruncate = "truncate"
+	ipModeRaw      = "raw"
+)
+
+// PlayerConfig controls the per player series exported for rcon targets. By default, players are only
+// identified by their steam id.
+type PlayerConfig struct {
+	// Disabled drops every per player series, leaving only the server level metrics.
+	Disabled bool `yaml:"disabled"`
+	// Labels lists the player attributes to export: userid, name, ip and port.
+	Labels []string `yaml:"labels"`
+	// InfoMetric exports the attributes on a single srcds_status_player_info series per player instead of
+	// adding them to every per player series.
+	InfoMetric bool `yaml:"info_metric"`
+	// IPMode controls how the ip is exported, either hash (default), truncate to the /24 (/48 for ipv6)
+	// network or raw.
+	IPMode string `yaml:"ip_mode"`
+	// HashSalt is the secret key of the hmac used to hash ips, so they can not be recovered by hashing every
+	// address. Required when exporting hashed ips.
+	HashSalt string `yaml:"hash_salt"`
+}
+
+// attributes returns the configured labels describing the player.
+func (c PlayerConfig) attributes(player statusPlayer) prometheus.Labels {
+	labels := prometheus.Labels{}
+
+	for _, name := range c.Labels {
+		switch name {
+		case playerLabelUserID:
+			labels[name] = strconv.Itoa(player.userID)
+		case playerLabelName:
+			// Names are truncated by the engine at a byte limit, which can split a multibyte character.
+			labels[name] = labelValue(player.name)
+		case playerLabelIP:
+			labels[name] = c.redactIP(player.ip)
+		case playerLabelPort:
+			labels[name] = strconv.Itoa(player.port)
+		}
+	}
+
+	return labels
+}
+
+// redactIP applies the IPMode to the address.
+func (c PlayerConfig) redactIP(addr string) string {
+	switch c.IPMode {
+	case ipModeRaw:
+		return addr
+	case ipModeTruncate:
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return ""
+		}
+
+		if ipv4 := ip.To4(); ipv4 != nil {
+			return (&net.IPNet{IP: ipv4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+		}
+
+		return (&net.IPNet{IP: ip.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+	default:
+		if addr == "" {
+			return ""
+		}
+
+		mac := hmac.New(sha256.New, []byte(c.HashSalt))
+		_, _ = mac.Write([]byte(addr))
+
+		return hex.EncodeToString(mac.Sum(nil)[:8])
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlayerConfigAttributes(t *testing.T) {
+	player := statusPlayer{userID: 12, name: "player", ip: "10.1.2.3", port: 27005}
+
+	playerConfig := PlayerConfig{Labels: []string{playerLabelUserID, playerLabelName, playerLabelIP, playerLabelPort}, IPMode: ipModeRaw}
+	require.Equal(t, prometheus.Labels{"userid": "12", "name": "player", "ip": "10.1.2.3", "port": "27005"}, playerConfig.attributes(player))
+
+	playerConfig = PlayerConfig{Labels: []string{playerLabelIP}, IPMode: ipModeTruncate}
+	require.Equal(t, prometheus.Labels{"ip": "10.1.2.0/24"}, playerConfig.attributes(player))
+	require.Equal(t, "2001:db8:1::/48", playerConfig.redactIP("2001:db8:1:2::1"))
+
+	playerConfig = PlayerConfig{Labels: []string{playerLabelIP}, IPMode: ipModeHash, HashSalt: "salt"}
+	hashed := playerConfig.attributes(player)["ip"]
+	require.Len(t, hashed, 16)
+	require.NotContains(t, hashed, "10.1.2.3")
+
+	playerConfig.HashSalt = "other"
+	require.NotEqual(t, hashed, playerConfig.attributes(player)["ip"])
+
+	require.Empty(t, PlayerConfig{}.attributes(player))
+
+	player.name = "abc\xe2\x82"
+	playerConfig = PlayerConfig{Labels: []string{playerLabelName}}
+	require.Equal(t, prometheus.Labels{"name": "abc�"}, playerConfig.attributes(player))
+}
+
+func TestConfigPlayers(t *testing.T) {
+	conf := newConfig()
+	require.NoError(t, conf.read(strings.NewReader(`players:
+  labels: [name, ip]
+  hash_salt: secret
+`)))
+	require.Equal(t, ipModeHash, conf.Players.IPMode)
+
+	conf = newConfig()
+	errRead := conf.read(strings.NewReader(`players:
+  labels: [name, email]
+  ip_mode: plain
+`))
+
+	var errs configErrors
+
+	require.ErrorAs(t, errRead, &errs)
+	require.Equal(t, configErrors{
+		{line: 2, field: "players.labels[1]", message: `unknown player label "email", must be one of userid, name, ip, port`},
+		{line: 3, field: "players.ip_mode", message: "must be one of hash, truncate or raw"},
+	}, errs)
+
+	conf = newConfig()
+	errRead = conf.read(strings.NewReader(`players:
+  labels: [ip]
+`))
+
+	require.ErrorAs(t, errRead, &errs)
+	require.Equal(t, configErrors{
+		{line: 1, field: "players.hash_salt", message: "is required when exporting hashed ips"},
+	}, errs)
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Failure classes used for the scrape_error metric.
+const (
+	errClassDial    = "dial"
+	errClassAuth    = "auth"
+	errClassTimeout = "timeout"
+	errClassExec    = "exec"
+	errClassParse   = "parse"
+)
+
+var errClasses = []string{errClassDial, errClassAuth, errClassTimeout, errClassExec, errClassParse} //nolint:gochecknoglobals
+
+// classifyError maps a poll error onto one of the failure classes.
+func classifyError(err error) string {
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return errClassTimeout
+	case errors.Is(err, errAuth):
+		return errClassAuth
+	case errors.Is(err, errDial):
+		return errClassDial
+	case errors.Is(err, errParse):
+		return errClassParse
+	default:
+		return errClassExec
+	}
+}
+
+// snapshot holds the most recent poll results for a single target.
+type snapshot struct {
+	status      *status
+	lastSuccess time.Time
+	lastAttempt time.Time
+	// errClass is the failure class of the last attempt, or empty if it succeeded.
+	errClass string
+	churn    playerChurn
+	maps     mapHistory
+	restarts restartHistory
+}
+
+func (s snapshot) online() bool {
+	return s.errClass == "" && s.status != nil
+}
+
+// statusCache stores the latest known status of each target, keyed by Target.Name, so that
+// scrapes can be answered without touching the game servers.
+type statusCache struct {
+	mu        sync.RWMutex
+	snapshots map[string]snapshot
+}
+
+func newStatusCache() *statusCache {
+	return &statusCache{snapshots: map[string]snapshot{}}
+}
+
+func (c *statusCache) remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.snapshots, name)
+}
+
+func (c *statusCache) get(name string) (snapshot, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	snap, found := c.snapshots[name]
+
+	return snap, found
+}
+
+// update records the result of a poll. Failed polls only record the attempt time and failure class,
+// the last good status is retained so its age can still be reported, and players are compared against
+// it once the server recovers.
+func (c *statusCache) update(name string, newStatus *status, errPoll error, attempted time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snap := c.snapshots[name]
+
+	wasOnline := snap.online()
+
+	// Time is only credited to a map between consecutive successful polls.
+	var previous *status
+	if wasOnline {
+		previous = snap.status
+	}
+
+	snap.lastAttempt = attempted
+	snap.errClass = ""
+
+	if errPoll != nil {
+		snap.errClass = classifyError(errPoll)
+	} else {
+		snap.churn = snap.churn.observe(snap.status, newStatus, attempted)
+		snap.maps = snap.maps.observe(previous, snap.lastSuccess, newStatus, attempted)
+		snap.restarts = snap.restarts.observe(snap.status, wasOnline, newStatus, attempted)
+		snap.status = newStatus
+		snap.lastSuccess = attempted
+	}
+
+	c.snapshots[name] = snap
+}
+
+// statusPoller periodically fetches the status of every rcon target in the background and stores
+// the results in a statusCache.
+type statusPoller struct {
+	cache *statusCache
+	conns *connPool
+	loops *pollGroup
+	// store records the players of each successful poll when enabled.
+	store *playerStore
+}
+
+func newStatusPoller(cache *statusCache, store *playerStore) *statusPoller {
+	poller := &statusPoller{cache: cache, conns: newConnPool(), store: store}
+	poller.loops = newPollGroup(poller.poll, func(target Target) {
+		cache.remove(target.Name)
+		poller.conns.remove(target.Name)
+	})
+
+	return poller
+}
+
+// start launches a polling loop for each target. The persistent connections and player store are closed
+// once the context is cancelled and the loops have exited.
+func (p *statusPoller) start(ctx context.Context, targets []Target) {
+	p.sync(ctx, targets)
+
+	go func() {
+		<-ctx.Done()
+		p.loops.wait()
+		p.conns.close()
+
+		if p.store != nil {
+			p.store.close()
+		}
+	}()
+}
+
+// sync starts and stops polling loops to match the rcon targets in the list.
+func (p *statusPoller) sync(ctx context.Context, targets []Target) {
+	p.loops.sync(ctx, filterTargets(targets, protocolRCON))
+}
+
+// filterTargets returns the targets using the protocol.
+func filterTargets(targets []Target, protocol string) []Target {
+	var filtered []Target //nolint:prealloc
+
+	for _, target := range targets {
+		if target.Protocol != protocol {
+			continue
+		}
+
+		filtered = append(filtered, target)
+	}
+
+	return filtered
+}
+
+type pollLoop struct {
+	target Target
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// pollGroup runs a background polling loop per target, keyed by Target.Name. When synced with an updated
+// target list, loops for removed or modified targets are stopped and new loops started in their place.
+type pollGroup struct {
+	mu    sync.Mutex
+	loops map[string]pollLoop
+	poll  func(ctx context.Context, target Target)
+	// stopped is called once the loop of a removed or modified target has exited.
+	stopped func(target Target)
+}
+
+func newPollGroup(poll func(ctx context.Context, target Target), stopped func(target Target)) *pollGroup {
+	return &pollGroup{loops: map[string]pollLoop{}, poll: poll, stopped: stopped}
+}
+
+func (g *pollGroup) sync(ctx context.Context, targets []Target) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	wanted := map[string]Target{}
+	for _, target := range targets {
+		wanted[target.Name] = target
+	}
+
+	for name, loop := range g.loops {
+		if target, found := wanted[name]; found && reflect.DeepEqual(target, loop.target) {
+			continue
+		}
+
+		loop.cancel()
+		<-loop.done
+		delete(g.loops, name)
+		g.stopped(loop.target)
+
+		slog.Debug("Stopped polling", slog.String("server", name))
+	}
+
+	for name, target := range wanted {
+		if _, found := g.loops[name]; found {
+			continue
+		}
+
+		loopCtx, cancel := context.WithCancel(ctx)
+		loop := pollLoop{target: target, cancel: cancel, done: make(chan struct{})}
+
+		go func() {
+			defer close(loop.done)
+
+			runEvery(loopCtx, target.Interval, func(ctx context.Context) {
+				g.poll(ctx, target)
+			})
+		}()
+
+		g.loops[name] = loop
+
+		slog.Debug("Started polling", slog.String("server", name))
+	}
+}
+
+// wait blocks until every loop has exited.
+func (g *pollGroup) wait() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, loop := range g.loops {
+		<-loop.done
+	}
+}
+
+// runEvery calls fn immediately and then once per interval until the context is cancelled.
+func runEvery(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		fn(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *statusPoller) poll(ctx context.Context, target Target) {
+	pollCtx, cancel := context.WithTimeout(ctx, target.Interval)
+	defer cancel()
+
+	attempted := time.Now()
+
+	newStatus, errStatus := p.fetch(pollCtx, target)
+	if errStatus != nil {
+		slog.Error("Failed to get status", slog.String("server", target.Name), slog.String("error", errStatus.Error()))
+	} else {
+		slog.Debug("Got status", slog.String("map", newStatus.Map), slog.String("server", target.Name))
+	}
+
+	p.cache.update(target.Name, newStatus, errStatus, attempted)
+
+	if errStatus == nil && p.store != nil {
+		if errRecord := p.store.record(target.Name, newStatus, attempted); errRecord != nil {
+			slog.Error("Failed to record players", slog.String("server", target.Name), slog.String("error", errRecord.Error()))
+		}
+	}
+}
+
+func (p *statusPoller) fetch(ctx context.Context, target Target) (*status, error) {
+	return fetchStatus(ctx, p.conns.get(target))
+}
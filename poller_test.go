@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusCacheUpdate(t *testing.T) {
+	cache := newStatusCache()
+
+	_, found := cache.get("test")
+	require.False(t, found)
+
+	first := time.Now()
+	cache.update("test", &status{Map: "pl_upward"}, nil, first)
+
+	snap, found := cache.get("test")
+	require.True(t, found)
+	require.Equal(t, "pl_upward", snap.status.Map)
+	require.Equal(t, first, snap.lastSuccess)
+	require.True(t, snap.online())
+
+	// A failed poll keeps the previous status to compare the next one against, but the collector only exports
+	// the online, scrape_error and age series until a poll succeeds
+	second := first.Add(time.Second)
+	cache.update("test", nil, errors.Join(errors.New("refused"), errDial), second)
+
+	snap, _ = cache.get("test")
+	require.False(t, snap.online())
+	require.Equal(t, errClassDial, snap.errClass)
+	require.Equal(t, "pl_upward", snap.status.Map)
+	require.Equal(t, first, snap.lastSuccess)
+	require.Equal(t, second, snap.lastAttempt)
+
+	conf := newConfig()
+	conf.Targets = []Target{{Name: "test", Protocol: protocolRCON, Game: gameTF2}}
+
+	text := scrape(t, newStatusCollector(conf, cache))
+	require.Contains(t, text, `srcds_stats_online{server="test"} 0`)
+	require.Contains(t, text, `srcds_scrape_error{class="dial",server="test"} 1`)
+	require.Contains(t, text, `srcds_status_last_success_timestamp{server="test"}`)
+	require.Contains(t, text, `srcds_status_age_seconds{server="test"}`)
+	require.NotContains(t, text, "srcds_map_info{")
+	require.NotContains(t, text, "srcds_status_players_count{")
+}
+
+func TestClassifyError(t *testing.T) {
+	require.Equal(t, errClassAuth, classifyError(errors.Join(errors.New("bad password"), errAuth)))
+	require.Equal(t, errClassDial, classifyError(errors.Join(errBackoff, errors.Join(errors.New("refused"), errDial))))
+	require.Equal(t, errClassTimeout, classifyError(errors.Join(context.DeadlineExceeded, errExec)))
+	require.Equal(t, errClassExec, classifyError(errors.Join(errors.New("reset"), errExec)))
+	require.Equal(t, errClassParse, classifyError(errEmptyStatus))
+}
+
+func TestPollGroupSync(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		polled  = map[string]int{}
+		stopped []string
+	)
+
+	group := newPollGroup(func(_ context.Context, target Target) {
+		mu.Lock()
+		polled[target.Name+target.Host]++
+		mu.Unlock()
+	}, func(target Target) {
+		stopped = append(stopped, target.Name+target.Host)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	group.sync(ctx, []Target{
+		{Name: "a", Host: "1", Interval: time.Hour},
+		{Name: "b", Host: "1", Interval: time.Hour},
+	})
+	group.sync(ctx, []Target{
+		{Name: "a", Host: "2", Interval: time.Hour},
+		{Name: "c", Host: "1", Interval: time.Hour},
+	})
+
+	require.ElementsMatch(t, []string{"a1", "b1"}, stopped)
+	require.Len(t, group.loops, 2)
+
+	cancel()
+	group.wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.Equal(t, map[string]int{"a1": 1, "b1": 1, "a2": 1, "c1": 1}, polled)
+}
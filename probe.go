@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	defaultProbeModule  = "default"
+	defaultProbeTimeout = time.Second * 10
+)
+
+// probeHandler serves metrics for a single ad-hoc target in the style of the blackbox_exporter, eg:
+// /probe?target=host:port&module=name. Credentials are taken from the named module in the config.
+func probeHandler(currentConfig func() *config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config := currentConfig()
+		query := r.URL.Query()
+
+		moduleName := query.Get("module")
+		if moduleName == "" {
+			moduleName = defaultProbeModule
+		}
+
+		module, found := config.Modules[moduleName]
+		if !found {
+			http.Error(w, "Unknown module: "+moduleName, http.StatusBadRequest)
+
+			return
+		}
+
+		target, errTarget := newProbeTarget(query.Get("target"), module)
+		if errTarget != nil {
+			http.Error(w, errTarget.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		target.Interval = probeTimeout(r)
+
+		ctx, cancel := context.WithTimeout(r.Context(), target.Interval)
+		defer cancel()
+
+		host, errAllowed := module.allow(ctx, target.Host)
+		if errAllowed != nil {
+			http.Error(w, errAllowed.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		target.Host = host
+
+		registry := prometheus.NewRegistry()
+		if errRegister := registry.Register(newProbeCollector(ctx, config, target)); errRegister != nil {
+			slog.Error("Failed to register probe collector", slog.String("error", errRegister.Error()))
+			http.Error(w, errRegister.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError}).ServeHTTP(w, r)
+	}
+}
+
+// newProbeTarget builds a target from a host:port string using the credentials of the module. The
+// address is also used as the server label.
+func newProbeTarget(addr string, module Module) (Target, error) {
+	host, portStr, errSplit := net.SplitHostPort(addr)
+	if errSplit != nil {
+		return Target{}, errSplit
+	}
+
+	port, errPort := strconv.ParseUint(portStr, 10, 16)
+	if errPort != nil || port == 0 {
+		return Target{}, errInvalidPort
+	}
+
+	return Target{
+		Name:     addr,
+		Host:     host,
+		Port:     uint16(port),
+		Password: module.Password,
+		Protocol: module.Protocol,
+		Game:     module.Game,
+	}, nil
+}
+
+var errProbeNotAllowed = errors.New("target is not allowed by the module")
+
+// allow checks the host against AllowedTargets and returns the address to connect to. Hostnames which are not
+// listed themselves are resolved, and every address must be allowed. The checked address is returned, so a
+// second lookup can not resolve to a different host. An empty list allows any host.
+func (m Module) allow(ctx context.Context, host string) (string, error) {
+	if len(m.AllowedTargets) == 0 || slices.ContainsFunc(m.AllowedTargets, func(allowed string) bool {
+		return strings.EqualFold(strings.TrimSuffix(allowed, "."), strings.TrimSuffix(host, "."))
+	}) {
+		return host, nil
+	}
+
+	var ips []net.IP
+
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, errLookup := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if errLookup != nil {
+			return "", errors.Join(errLookup, errProbeNotAllowed)
+		}
+
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	for _, ip := range ips {
+		if !slices.ContainsFunc(m.AllowedTargets, func(allowed string) bool {
+			if _, network, errCIDR := net.ParseCIDR(allowed); errCIDR == nil {
+				return network.Contains(ip)
+			}
+
+			return ip.Equal(net.ParseIP(allowed))
+		}) {
+			return "", errProbeNotAllowed
+		}
+	}
+
+	if len(ips) == 0 {
+		return "", errProbeNotAllowed
+	}
+
+	return ips[0].String(), nil
+}
+
+// probeTimeout uses the scrape timeout sent by prometheus, leaving some headroom to write the response.
+func probeTimeout(r *http.Request) time.Duration {
+	seconds := toFloat64Default(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 0)
+	if seconds <= 1 {
+		return defaultProbeTimeout
+	}
+
+	return time.Duration((seconds - 0.5) * float64(time.Second))
+}
+
+// newProbeCollector polls the target once and returns a collector serving only its results.
+func newProbeCollector(ctx context.Context, config *config, target Target) *rootCollector {
+	probeConfig := *config
+	probeConfig.Targets = []Target{target}
+
+	switch target.Protocol {
+	case protocolA2S:
+		a2s := newA2SCollector(&probeConfig)
+		a2s.poll(ctx, target)
+
+		return newRootCollector(ctx, a2s)
+	default:
+		cache := newStatusCache()
+		poller := newStatusPoller(cache, nil)
+		poller.poll(ctx, target)
+		poller.conns.close()
+
+		return newRootCollector(ctx, newStatusCollector(&probeConfig, cache))
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeHandler(t *testing.T) {
+	addr := startA2STestServer(t)
+
+	conf := newConfig()
+	conf.Modules = map[string]Module{"community": {Protocol: protocolA2S}}
+
+	recorder := httptest.NewRecorder()
+	probeHandler(func() *config { return conf }).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/probe?module=community&target="+addr, nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	body, errRead := io.ReadAll(recorder.Body)
+	require.NoError(t, errRead)
+	require.Contains(t, string(body), `srcds_a2s_map{map="pl_upward",server="`+addr+`"} 1`)
+	require.Contains(t, string(body), `srcds_stats_online{server="`+addr+`"} 1`)
+
+	recorder = httptest.NewRecorder()
+	probeHandler(func() *config { return conf }).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/probe?module=unknown&target="+addr, nil))
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	probeHandler(func() *config { return conf }).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/probe?module=community&target=localhost", nil))
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestProbeHandlerRCON(t *testing.T) {
+	addr := startGoldSrcTestServer(t, "secret")
+
+	conf := newConfig()
+	conf.Modules = map[string]Module{
+		"default": {Protocol: protocolRCON, Game: gameGoldSrc, Password: "secret", AllowedTargets: []string{"127.0.0.0/8"}},
+		"other":   {Protocol: protocolRCON, Game: gameGoldSrc, Password: "secret", AllowedTargets: []string{"10.0.0.1", "srcds.example.com"}},
+	}
+
+	recorder := httptest.NewRecorder()
+	probeHandler(func() *config { return conf }).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/probe?target="+addr, nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), `srcds_stats_online{server="`+addr+`"} 1`)
+	require.Contains(t, recorder.Body.String(), `srcds_map_info{map="de_dust2",server="`+addr+`"} 1`)
+
+	// Targets outside the allowed list are rejected before connecting
+	recorder = httptest.NewRecorder()
+	probeHandler(func() *config { return conf }).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/probe?module=other&target="+addr, nil))
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	require.Contains(t, recorder.Body.String(), errProbeNotAllowed.Error())
+
+	recorder = httptest.NewRecorder()
+	probeHandler(func() *config { return conf }).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/probe?target=192.168.0.1:27015", nil))
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestModuleAllow(t *testing.T) {
+	module := Module{AllowedTargets: []string{"10.0.0.0/24", "192.168.0.5", "srcds.example.com"}}
+
+	for host, expected := range map[string]string{
+		"10.0.0.20":          "10.0.0.20",
+		"192.168.0.5":        "192.168.0.5",
+		"SRCDS.example.com.": "SRCDS.example.com.",
+		"10.0.1.1":           "",
+		"192.168.0.6":        "",
+	} {
+		allowed, errAllowed := module.allow(context.Background(), host)
+		if expected == "" {
+			require.ErrorIs(t, errAllowed, errProbeNotAllowed, host)
+		} else {
+			require.NoError(t, errAllowed, host)
+			require.Equal(t, expected, allowed, host)
+		}
+	}
+}
+
+func TestConfigModuleAllowedTargets(t *testing.T) {
+	conf := newConfig()
+	errRead := conf.read(strings.NewReader(`modules:
+  default:
+    password: pass
+  other:
+    password: pass
+    allowed_targets: [10.0.0.0/8, "bad host!"]
+  community:
+    protocol: a2s
+`))
+
+	var errs configErrors
+
+	require.ErrorAs(t, errRead, &errs)
+	require.Equal(t, configErrors{
+		{line: 2, field: "modules.default.allowed_targets", message: "is required for the rcon protocol"},
+		{line: 6, field: "modules.other.allowed_targets[1]", message: `"bad host!" is not a valid hostname, ip address or cidr`},
+	}, errs)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// reloadDebounce groups the burst of events editors generate when saving into a single reload.
+const reloadDebounce = time.Second
+
+// watch reloads the config on SIGHUP or whenever the config file changes, until the context is cancelled.
+func (app *application) watch(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	defer signal.Stop(hangup)
+
+	changed := app.watchFile(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			slog.Info("SIGHUP received, reloading config")
+		case <-changed:
+			slog.Info("Config file changed, reloading config")
+		}
+
+		if errReload := app.reloadConfig(ctx); errReload != nil {
+			slog.Error("Failed to reload config, keeping current config", slog.String("error", errReload.Error()))
+		}
+	}
+}
+
+// watchFile returns a channel which receives a value after the config file has been modified. The parent
+// directory is watched rather than the file itself, since many editors replace the file instead of writing
+// to it. Kubernetes config maps update the file by swapping the ..data symlink it points through, which
+// produces no event for the file, so the symlinks are resolved again on every event in the directory.
+func (app *application) watchFile(ctx context.Context) <-chan struct{} {
+	changed := make(chan struct{}, 1)
+
+	if !app.configRequired {
+		return changed
+	}
+
+	configPath, errAbs := filepath.Abs(app.configPath)
+	if errAbs != nil {
+		slog.Error("Failed to resolve config path", slog.String("error", errAbs.Error()))
+
+		return changed
+	}
+
+	watcher, errWatcher := fsnotify.NewWatcher()
+	if errWatcher != nil {
+		slog.Error("Failed to create config file watcher", slog.String("error", errWatcher.Error()))
+
+		return changed
+	}
+
+	if errAdd := watcher.Add(filepath.Dir(configPath)); errAdd != nil {
+		slog.Error("Failed to watch config file", slog.String("error", errAdd.Error()))
+
+		_ = watcher.Close()
+
+		return changed
+	}
+
+	// An error leaves the path empty, so the next event resolving the file counts as a change.
+	resolved, _ := filepath.EvalSymlinks(configPath)
+
+	go func() {
+		defer func() {
+			_ = watcher.Close()
+		}()
+
+		debounce := time.NewTimer(reloadDebounce)
+		debounce.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-watcher.Events:
+				current, _ := filepath.EvalSymlinks(configPath)
+				swapped := current != resolved
+				resolved = current
+
+				if !swapped && (filepath.Clean(event.Name) != configPath || !event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename)) {
+					continue
+				}
+
+				debounce.Reset(reloadDebounce)
+			case errWatch := <-watcher.Errors:
+				slog.Error("Config file watcher error", slog.String("error", errWatch.Error()))
+			case <-debounce.C:
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return changed
+}
+
+// reloadConfig reads the config file and applies the changes. Pollers are only restarted for targets which
+// were added, removed or modified. The HTTP listener is not restarted, so changes to its settings and the
+// log listener require a restart.
+func (app *application) reloadConfig(ctx context.Context) error {
+	newConfig, errConfig := readConfigFile(app.configPath, app.configRequired)
+	if errConfig != nil {
+		app.reload.update(false)
+
+		return errConfig
+	}
+
+	for _, warning := range restartWarnings(app.currentConfig(), newConfig) {
+		slog.Warn(warning)
+	}
+
+	app.config.Store(newConfig)
+	app.poller.sync(ctx, newConfig.Targets)
+	app.a2s.reload(ctx, newConfig)
+	app.commands.reload(ctx, newConfig)
+
+	if app.logs != nil {
+		app.logs.reload(ctx, newConfig)
+	}
+
+	if app.store != nil {
+		app.store.reload(newConfig)
+	}
+
+	app.root.setCollectors(app.collectors(newConfig)...)
+	app.reload.update(true)
+
+	slog.Info("Config reloaded", slog.Int("targets", len(newConfig.Targets)))
+
+	return nil
+}
+
+// restartWarnings describes the changed settings which are only applied on restart, so the operator is not
+// left assuming they took effect.
+func restartWarnings(current *config, newConfig *config) []string {
+	var warnings []string
+
+	if newConfig.Addr() != current.Addr() || newConfig.MetricsPath != current.MetricsPath || newConfig.ProbePath != current.ProbePath {
+		warnings = append(warnings, "Changes to the listen address, metrics_path or probe_path require a restart")
+	}
+
+	for _, setting := range []struct {
+		name     string
+		current  string
+		newValue string
+	}{
+		{name: "log_listen_addr", current: current.LogListenAddr, newValue: newConfig.LogListenAddr},
+		{name: "store_path", current: current.StorePath, newValue: newConfig.StorePath},
+	} {
+		switch {
+		case setting.current == setting.newValue:
+		case setting.current == "":
+			warnings = append(warnings, "Enabling "+setting.name+" requires a restart")
+		case setting.newValue == "":
+			warnings = append(warnings, "Disabling "+setting.name+" requires a restart")
+		default:
+			warnings = append(warnings, "Changes to "+setting.name+" require a restart")
+		}
+	}
+
+	return warnings
+}
+
+// reloadCollector exports the result of the most recent config reload.
+type reloadCollector struct {
+	mu          sync.RWMutex
+	success     bool
+	successTime time.Time
+}
+
+func newReloadCollector() *reloadCollector {
+	return &reloadCollector{success: true, successTime: time.Now()}
+}
+
+func (c *reloadCollector) Name() string {
+	return "reload"
+}
+
+func (c *reloadCollector) update(success bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.success = success
+
+	if success {
+		c.successTime = time.Now()
+	}
+}
+
+func (c *reloadCollector) Update(_ context.Context, metricCHan chan<- prometheus.Metric) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	success := prometheus.NewDesc(
+		prometheus.BuildFQName("srcds_watch", "config", "last_reload_success"),
+		"1 if the last config reload attempt was successful",
+		nil, nil)
+	successTime := prometheus.NewDesc(
+		prometheus.BuildFQName("srcds_watch", "config", "last_reload_success_timestamp_seconds"),
+		"The unix timestamp of the last successful config reload",
+		nil, nil)
+
+	if c.success {
+		metricCHan <- prometheus.MustNewConstMetric(success, prometheus.GaugeValue, 1)
+	} else {
+		metricCHan <- prometheus.MustNewConstMetric(success, prometheus.GaugeValue, 0)
+	}
+
+	metricCHan <- prometheus.MustNewConstMetric(successTime, prometheus.GaugeValue, float64(c.successTime.Unix()))
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestartWarnings(t *testing.T) {
+	current := newConfig()
+	current.LogListenAddr = "0.0.0.0:27500"
+
+	newConfig := *current
+	require.Empty(t, restartWarnings(current, &newConfig))
+
+	newConfig.LogListenAddr = ""
+	newConfig.StorePath = "players.db"
+	newConfig.ListenPort = 9000
+	require.Equal(t, []string{
+		"Changes to the listen address, metrics_path or probe_path require a restart",
+		"Disabling log_listen_addr requires a restart",
+		"Enabling store_path requires a restart",
+	}, restartWarnings(current, &newConfig))
+
+	newConfig = *current
+	newConfig.LogListenAddr = "0.0.0.0:27501"
+	require.Equal(t, []string{"Changes to log_listen_addr require a restart"}, restartWarnings(current, &newConfig))
+}
+
+func TestWatchFileConfigMap(t *testing.T) {
+	// Lay out the directory the way kubernetes mounts a config map, the file links through ..data to a
+	// timestamped directory which is replaced on every update.
+	dir := t.TempDir()
+
+	for _, version := range []string{"..v1", "..v2"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, version), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, version, "srcds_watch.yml"), []byte("log_level: info\n"), 0o600))
+	}
+
+	require.NoError(t, os.Symlink("..v1", filepath.Join(dir, "..data")))
+	require.NoError(t, os.Symlink(filepath.Join("..data", "srcds_watch.yml"), filepath.Join(dir, "srcds_watch.yml")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	app := &application{configPath: filepath.Join(dir, "srcds_watch.yml"), configRequired: true}
+	changed := app.watchFile(ctx)
+
+	require.NoError(t, os.Symlink("..v2", filepath.Join(dir, "..data_tmp")))
+	require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+
+	select {
+	case <-changed:
+	case <-time.After(reloadDebounce * 5):
+		t.Fatal("config map update was not detected")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// Restart reasons, decided by whether the server answered the poll before the restart was seen.
+const (
+	restartPlanned = "planned"
+	restartCrash   = "crash"
+)
+
+var restartReasons = []string{restartPlanned, restartCrash} //nolint:gochecknoglobals
+
+// restartHistory counts the server restarts detected from successive statuses. Like playerChurn, values
+// are replaced rather than modified.
+type restartHistory struct {
+	counts map[string]uint64
+	// startTime is derived from the uptime of the first poll after the exporter or server started.
+	startTime time.Time
+}
+
+// observe returns the history updated with the current status. A restart is seen when the uptime goes
+// backwards. When the stats section is missing, so the uptime is unknown, the server coming back after
+// failed polls is counted instead. Restarts seen while the previous poll succeeded are considered planned,
+// eg: a map based restart or the _restart command, otherwise the server is assumed to have crashed.
+func (h restartHistory) observe(previous *status, wasOnline bool, current *status, now time.Time) restartHistory {
+	next := h
+	next.counts = maps.Clone(h.counts)
+
+	if next.counts == nil {
+		next.counts = map[string]uint64{restartPlanned: 0, restartCrash: 0}
+	}
+
+	hasUptime := !slices.Contains(current.MissingSections, sectionStats)
+	uptimeStart := now.Add(-time.Duration(current.Uptime) * time.Minute)
+
+	if previous == nil {
+		if hasUptime {
+			next.startTime = uptimeStart
+		}
+
+		return next
+	}
+
+	restarted := !wasOnline && !hasUptime
+	if hasUptime && !slices.Contains(previous.MissingSections, sectionStats) {
+		restarted = current.Uptime < previous.Uptime
+	}
+
+	if !restarted {
+		return next
+	}
+
+	if wasOnline {
+		next.counts[restartPlanned]++
+	} else {
+		next.counts[restartCrash]++
+	}
+
+	next.startTime = now
+	if hasUptime {
+		next.startTime = uptimeStart
+	}
+
+	return next
+}
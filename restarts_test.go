@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestartHistory(t *testing.T) {
+	now := time.Now()
+
+	history := restartHistory{}.observe(nil, false, &status{Uptime: 60}, now)
+	require.Equal(t, now.Add(-time.Hour), history.startTime)
+
+	history = history.observe(&status{Uptime: 60}, true, &status{Uptime: 61}, now.Add(time.Minute))
+	require.Zero(t, history.counts[restartPlanned]+history.counts[restartCrash])
+	require.Equal(t, now.Add(-time.Hour), history.startTime)
+
+	// Uptime going backwards between successful polls
+	history = history.observe(&status{Uptime: 61}, true, &status{Uptime: 0}, now.Add(time.Minute*2))
+	require.Equal(t, uint64(1), history.counts[restartPlanned])
+	require.Equal(t, now.Add(time.Minute*2), history.startTime)
+
+	// Uptime going backwards after failed polls
+	history = history.observe(&status{Uptime: 10}, false, &status{Uptime: 2}, now.Add(time.Hour))
+	require.Equal(t, uint64(1), history.counts[restartCrash])
+	require.Equal(t, now.Add(time.Hour-time.Minute*2), history.startTime)
+
+	// A network outage without the uptime going backwards is not a restart
+	history = history.observe(&status{Uptime: 2}, false, &status{Uptime: 20}, now.Add(time.Hour+time.Minute*18))
+	require.Equal(t, uint64(1), history.counts[restartCrash])
+
+	// Without the uptime, returning after failed polls is counted
+	noStats := &status{MissingSections: []string{sectionStats}}
+	history = history.observe(noStats, false, noStats, now.Add(time.Hour*2))
+	require.Equal(t, uint64(2), history.counts[restartCrash])
+	require.Equal(t, now.Add(time.Hour*2), history.startTime)
+
+	history = history.observe(noStats, true, noStats, now.Add(time.Hour*3))
+	require.Equal(t, uint64(2), history.counts[restartCrash])
+	require.Equal(t, uint64(1), history.counts[restartPlanned])
+}
@@ -4,19 +4,30 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
 	"regexp"
-	"strconv"
+	"slices"
 	"strings"
-	"sync"
 	"time"
+	"unicode"
 
-	"github.com/leighmacdonald/rcon/rcon"
 	"github.com/leighmacdonald/steamid/v4/steamid"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// Player states reported by status.
+const (
+	playerStateActive     = "active"
+	playerStateSpawning   = "spawning"
+	playerStateConnecting = "connecting"
+)
+
 type statusPlayer struct {
+	userID  int
+	name    string
+	state   string
+	bot     bool
 	online  int
 	ping    int
 	loss    int
@@ -27,14 +38,29 @@ type statusPlayer struct {
 }
 
 type status struct {
+	Hostname            string
+	Version             string
+	Build               string
+	Secure              bool
+	Address             string
+	ServerSteamID       string
+	Account             string
+	Tags                []string
 	Map                 string
 	Players             []statusPlayer
 	PlayerLimit         int
 	PlayersHumans       int
 	PlayersBots         int
 	Edicts              int
+	EdictsMax           int
 	SvVisibleMaxPlayers int
 	SourceTV            bool
+	SourceTVAddress     string
+	SourceTVLocal       string
+	SourceTVDelay       float64
+	SourceTVSpectators  int
+	SourceTVRelays      int
+	SourceTVRecording   bool
 	CPU                 float64
 	NetIn               float64
 	NetOut              float64
@@ -46,10 +72,16 @@ type status struct {
 	SvMaXUpdateRate     float64
 	SMVersion           string
 	MMVersion           string
+	// MissingSections lists the expected sections of the game's output which were not found.
+	MissingSections []string
+	// UnmatchedLines counts non-empty lines not recognised by the parser, UnmatchedSample holds the first few.
+	UnmatchedLines  int
+	UnmatchedSample []string
 }
 
 type statusCollector struct {
 	config *config
+	cache  *statusCache
 
 	connected           []*prometheus.Desc
 	online              []*prometheus.Desc
@@ -57,7 +89,6 @@ type statusCollector struct {
 	ping                []*prometheus.Desc
 	sourceTV            []*prometheus.Desc
 	loss                []*prometheus.Desc
-	mapName             []*prometheus.Desc
 	edicts              []*prometheus.Desc
 	playersCount        []*prometheus.Desc
 	playersLimit        []*prometheus.Desc
@@ -74,6 +105,9 @@ type statusCollector struct {
 	svMaxUpdateRate     []*prometheus.Desc
 	smVersion           []*prometheus.Desc
 	mmVersion           []*prometheus.Desc
+	lastSuccess         []*prometheus.Desc
+	age                 []*prometheus.Desc
+	scrapeError         []*prometheus.Desc
 }
 
 func createStatusDesc(namespace string, stat string, labels prometheus.Labels) *prometheus.Desc {
@@ -141,23 +175,148 @@ func createStatusDesc(namespace string, stat string, labels prometheus.Labels) *
 	case "online":
 		return prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "stats", stat),
-			"1 if the game server is online",
+			"1 if the game server is online, 0 if the last poll failed",
 			nil, labels)
 	case "source_tv":
 		return prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "stats", stat),
 			"The current status of source tv",
 			nil, labels)
+	case "sourcetv_info":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "sourcetv", "info"),
+			"The SourceTV public and local address",
+			nil, labels)
+	case "sourcetv_delay":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "sourcetv", "delay_seconds"),
+			"The SourceTV broadcast delay in seconds",
+			nil, labels)
+	case "sourcetv_spectators":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "sourcetv", "spectators"),
+			"The total number of SourceTV spectators, including those on relays",
+			nil, labels)
+	case "sourcetv_relays":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "sourcetv", "relays"),
+			"The total number of connected SourceTV relay proxies",
+			nil, labels)
+	case "sourcetv_recording":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "sourcetv", "recording"),
+			"1 if SourceTV is currently recording a demo",
+			nil, labels)
+	case "server_info":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server", "info"),
+			"The server hostname, build, VAC security, steam id and steam account login state",
+			nil, labels)
+	case "server_restarts":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server", "restarts_total"),
+			"The total number of server restarts seen, either planned or after a crash",
+			nil, labels)
+	case "server_start_time":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server", "start_time_seconds"),
+			"The unix timestamp the server was started at",
+			nil, labels)
+	case "server_tag":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server", "tag"),
+			"The tags currently set in sv_tags",
+			nil, labels)
+	case "parse_missing_section":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "parse", "missing_section"),
+			"1 if an expected section was missing from the last status output, usually after a game update changed the format",
+			nil, labels)
+	case "parse_unmatched_lines":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "parse", "unmatched_lines"),
+			"The number of lines in the last status output that were not recognised",
+			nil, labels)
+	case "player_joins":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "player", "joins_total"),
+			"The total number of human players seen joining the server",
+			nil, labels)
+	case "player_leaves":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "player", "leaves_total"),
+			"The total number of human players seen leaving the server",
+			nil, labels)
+	case "player_reconnects":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "player", "reconnects_total"),
+			"The total number of players rejoining within 5 minutes of leaving",
+			nil, labels)
+	case "player_session":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "player", "session_seconds"),
+			"The distribution of how long departed human players were connected for",
+			nil, labels)
+	case "map_info":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "map", "info"),
+			"The map currently being played",
+			nil, labels)
+	case "map_changes":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "map", "changes_total"),
+			"The total number of map changes seen",
+			nil, labels)
+	case "map_current_seconds":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "map", "current_seconds"),
+			"The number of seconds the current map has been played for",
+			nil, labels)
+	case "map_player_seconds":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "map", "player_seconds_total"),
+			"The total number of human players multiplied by the seconds spent on the map",
+			nil, labels)
+	case "players_by_state":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "status", stat),
+			"The current number of human players in each connection state, bots are counted under the bot state",
+			nil, labels)
 	case "edicts":
 		return prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "status", stat),
-			"The current edict usage (2048 max)",
+			"The current edict usage",
+			nil, labels)
+	case "edicts_max":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "status", stat),
+			"The maximum number of edicts, the server crashes once they are all used",
+			nil, labels)
+	case "edicts_peak":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "status", stat),
+			"The highest edict usage seen on the current map",
 			nil, labels)
 	case "connected":
 		return prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "status", stat),
 			"The duration the player has been connected for in seconds",
 			nil, labels)
+	case "session_seconds":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "status", stat),
+			"The distribution of how long the currently connected human players have been connected for",
+			nil, labels)
+	case "player_ping_milliseconds":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "status", stat),
+			"The distribution of the ping of the currently connected human players",
+			nil, labels)
+	case "player_info":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "status", stat),
+			"The configured attributes of a connected player",
+			nil, labels)
 	case "ping":
 		return prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "status", stat),
@@ -168,10 +327,20 @@ func createStatusDesc(namespace string, stat string, labels prometheus.Labels) *
 			prometheus.BuildFQName(namespace, "status", stat),
 			"The current player loss",
 			nil, labels)
-	case "map_name":
+	case "scrape_error":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", stat),
+			"1 if the last poll of the server failed with the labelled failure class",
+			nil, labels)
+	case "last_success_timestamp":
 		return prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "status", stat),
-			"The current map name",
+			"The unix timestamp of the last successful status poll",
+			nil, labels)
+	case "age_seconds":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "status", stat),
+			"The number of seconds since the last successful status poll",
 			nil, labels)
 	case "players_count":
 		return prometheus.NewDesc(
@@ -200,7 +369,7 @@ func createStatusDesc(namespace string, stat string, labels prometheus.Labels) *
 	return nil
 }
 
-func newStatusCollector(config *config) *statusCollector {
+func newStatusCollector(config *config, cache *statusCache) *statusCollector {
 	var ( //nolint:prealloc
 		connected           []*prometheus.Desc
 		online              []*prometheus.Desc
@@ -208,7 +377,6 @@ func newStatusCollector(config *config) *statusCollector {
 		svVisibleMaxPlayers []*prometheus.Desc
 		ping                []*prometheus.Desc
 		loss                []*prometheus.Desc
-		mapName             []*prometheus.Desc
 		edicts              []*prometheus.Desc
 		playersCount        []*prometheus.Desc
 		playersLimit        []*prometheus.Desc
@@ -225,10 +393,13 @@ func newStatusCollector(config *config) *statusCollector {
 		svMaxUpdateRate     []*prometheus.Desc
 		mmVersion           []*prometheus.Desc
 		smVersion           []*prometheus.Desc
+		lastSuccess         []*prometheus.Desc
+		age                 []*prometheus.Desc
+		scrapeError         []*prometheus.Desc
 	)
 
 	for _, server := range config.Targets {
-		labels := prometheus.Labels{"server": server.Name}
+		labels := config.targetLabels(server)
 
 		online = append(online, createStatusDesc(config.NameSpace, "online", labels))
 		connected = append(connected, createStatusDesc(config.NameSpace, "connected", labels))
@@ -236,7 +407,6 @@ func newStatusCollector(config *config) *statusCollector {
 		svVisibleMaxPlayers = append(svVisibleMaxPlayers, createStatusDesc(config.NameSpace, "sv_visiblemaxplayers", labels))
 		ping = append(ping, createStatusDesc(config.NameSpace, "ping", labels))
 		loss = append(loss, createStatusDesc(config.NameSpace, "loss", labels))
-		mapName = append(mapName, createStatusDesc(config.NameSpace, "map_name", labels))
 		edicts = append(edicts, createStatusDesc(config.NameSpace, "edicts", labels))
 		playersCount = append(playersCount, createStatusDesc(config.NameSpace, "players_count", labels))
 		playersLimit = append(playersLimit, createStatusDesc(config.NameSpace, "players_limit", labels))
@@ -253,10 +423,14 @@ func newStatusCollector(config *config) *statusCollector {
 		svMaxUpdateRate = append(svMaxUpdateRate, createStatusDesc(config.NameSpace, "sv_max_update_rate", labels))
 		mmVersion = append(mmVersion, createStatusDesc(config.NameSpace, "metamod_version", labels))
 		smVersion = append(smVersion, createStatusDesc(config.NameSpace, "sourcemod_version", labels))
+		lastSuccess = append(lastSuccess, createStatusDesc(config.NameSpace, "last_success_timestamp", labels))
+		age = append(age, createStatusDesc(config.NameSpace, "age_seconds", labels))
+		scrapeError = append(scrapeError, createStatusDesc(config.NameSpace, "scrape_error", labels))
 	}
 
 	return &statusCollector{
 		config:              config,
+		cache:               cache,
 		cpu:                 cpu,
 		netIn:               netIn,
 		netOut:              netOut,
@@ -274,12 +448,14 @@ func newStatusCollector(config *config) *statusCollector {
 		svVisibleMaxPlayers: svVisibleMaxPlayers,
 		ping:                ping,
 		loss:                loss,
-		mapName:             mapName,
 		edicts:              edicts,
 		playersCount:        playersCount,
 		playersLimit:        playersLimit,
 		playersHuman:        playersHuman,
 		playersBots:         playersBots,
+		lastSuccess:         lastSuccess,
+		age:                 age,
+		scrapeError:         scrapeError,
 	}
 }
 
@@ -290,106 +466,254 @@ func (s *statusCollector) Name() string {
 func (s *statusCollector) Describe(_ chan<- *prometheus.Desc) {
 }
 
-func (s *statusCollector) Update(ctx context.Context, metricCHan chan<- prometheus.Metric) error {
-	waitGroup := &sync.WaitGroup{}
+func (s *statusCollector) Update(_ context.Context, metricCHan chan<- prometheus.Metric) error {
+	now := time.Now()
+
+	for _, server := range s.config.Targets {
+		if server.Protocol != protocolRCON {
+			continue
+		}
+
+		snap, found := s.cache.get(server.Name)
+		if !found {
+			continue
+		}
 
-	for _, target := range s.config.Targets {
-		waitGroup.Add(1)
+		labels := s.config.targetLabels(server)
 
-		go func(server Target) {
-			defer waitGroup.Done()
+		online := createStatusDesc(s.config.NameSpace, "online", labels)
 
-			conn, errConn := rcon.Dial(ctx, server.addr(), server.Password, time.Second*8)
-			if errConn != nil {
-				slog.Error("Failed to connect", slog.String("server", server.Name), slog.String("error", errConn.Error()))
+		if snap.online() {
+			metricCHan <- prometheus.MustNewConstMetric(online, prometheus.GaugeValue, 1)
+		} else {
+			metricCHan <- prometheus.MustNewConstMetric(online, prometheus.GaugeValue, 0)
+		}
+
+		for _, class := range errClasses {
+			scrapeError := createStatusDesc(s.config.NameSpace, "scrape_error", mergeLabels(labels, prometheus.Labels{"class": class}))
 
-				return
+			if snap.errClass == class {
+				metricCHan <- prometheus.MustNewConstMetric(scrapeError, prometheus.GaugeValue, 1)
+			} else {
+				metricCHan <- prometheus.MustNewConstMetric(scrapeError, prometheus.GaugeValue, 0)
 			}
+		}
 
-			defer func() {
-				if err := conn.Close(); err != nil {
-					slog.Error("Failed to close connection", slog.String("server", server.Name), slog.String("error", errConn.Error()))
-				}
-			}()
+		if !snap.lastSuccess.IsZero() {
+			lastSuccess := createStatusDesc(s.config.NameSpace, "last_success_timestamp", labels)
+			age := createStatusDesc(s.config.NameSpace, "age_seconds", labels)
+
+			metricCHan <- prometheus.MustNewConstMetric(lastSuccess, prometheus.GaugeValue, float64(snap.lastSuccess.Unix()))
+			metricCHan <- prometheus.MustNewConstMetric(age, prometheus.GaugeValue, now.Sub(snap.lastSuccess).Seconds())
+		}
+
+		joins := createStatusDesc(s.config.NameSpace, "player_joins", labels)
+		leaves := createStatusDesc(s.config.NameSpace, "player_leaves", labels)
+		reconnects := createStatusDesc(s.config.NameSpace, "player_reconnects", labels)
+		session := createStatusDesc(s.config.NameSpace, "player_session", labels)
+
+		metricCHan <- prometheus.MustNewConstMetric(joins, prometheus.CounterValue, float64(snap.churn.joins))
+		metricCHan <- prometheus.MustNewConstMetric(leaves, prometheus.CounterValue, float64(snap.churn.leaves))
+		metricCHan <- prometheus.MustNewConstMetric(reconnects, prometheus.CounterValue, float64(snap.churn.reconnects))
+		metricCHan <- prometheus.MustNewConstHistogram(session, snap.churn.sessionCount, snap.churn.sessionSum, snap.churn.sessionBuckets)
 
-			newStatus, errStats := fetchStatus(conn)
-			if errStats != nil {
-				slog.Error("Failed to get status", slog.String("server", server.Name), slog.String("error", errStats.Error()))
+		for mapName, seconds := range snap.maps.playerSeconds {
+			playerSeconds := createStatusDesc(s.config.NameSpace, "map_player_seconds", mergeLabels(labels, prometheus.Labels{"map": mapName}))
+			metricCHan <- prometheus.MustNewConstMetric(playerSeconds, prometheus.CounterValue, seconds)
+		}
+
+		mapChanges := createStatusDesc(s.config.NameSpace, "map_changes", labels)
+		metricCHan <- prometheus.MustNewConstMetric(mapChanges, prometheus.CounterValue, float64(snap.maps.changes))
+
+		for _, reason := range restartReasons {
+			restarts := createStatusDesc(s.config.NameSpace, "server_restarts", mergeLabels(labels, prometheus.Labels{"reason": reason}))
+			metricCHan <- prometheus.MustNewConstMetric(restarts, prometheus.CounterValue, float64(snap.restarts.counts[reason]))
+		}
+
+		if !snap.restarts.startTime.IsZero() {
+			startTime := createStatusDesc(s.config.NameSpace, "server_start_time", labels)
+			metricCHan <- prometheus.MustNewConstMetric(startTime, prometheus.GaugeValue, float64(snap.restarts.startTime.Unix()))
+		}
+
+		if !snap.online() {
+			continue
+		}
+
+		newStatus := snap.status
+
+		mapInfo := createStatusDesc(s.config.NameSpace, "map_info", mergeLabels(labels, prometheus.Labels{"map": newStatus.Map}))
+		mapSeconds := createStatusDesc(s.config.NameSpace, "map_current_seconds", labels)
+
+		metricCHan <- prometheus.MustNewConstMetric(mapInfo, prometheus.GaugeValue, 1)
+		metricCHan <- prometheus.MustNewConstMetric(mapSeconds, prometheus.GaugeValue, now.Sub(snap.maps.since).Seconds())
+
+		for _, section := range gameSections(server.Game) {
+			missing := createStatusDesc(s.config.NameSpace, "parse_missing_section", mergeLabels(labels, prometheus.Labels{"section": section}))
 
-				return
+			if slices.Contains(newStatus.MissingSections, section) {
+				metricCHan <- prometheus.MustNewConstMetric(missing, prometheus.GaugeValue, 1)
+			} else {
+				metricCHan <- prometheus.MustNewConstMetric(missing, prometheus.GaugeValue, 0)
 			}
+		}
 
-			if newStatus == nil {
-				slog.Error("No status returned", slog.String("server", server.Name))
+		unmatched := createStatusDesc(s.config.NameSpace, "parse_unmatched_lines", labels)
+		metricCHan <- prometheus.MustNewConstMetric(unmatched, prometheus.GaugeValue, float64(newStatus.UnmatchedLines))
+
+		secure := "insecure"
+		if newStatus.Secure {
+			secure = "secure"
+		}
 
-				return
+		serverInfo := createStatusDesc(s.config.NameSpace, "server_info", mergeLabels(labels, prometheus.Labels{
+			"hostname":        newStatus.Hostname,
+			"build":           newStatus.Build,
+			"secure":          secure,
+			"server_steam_id": newStatus.ServerSteamID,
+			"account":         newStatus.Account,
+		}))
+
+		metricCHan <- prometheus.MustNewConstMetric(serverInfo, prometheus.GaugeValue, 1)
+
+		for _, tag := range newStatus.Tags {
+			serverTag := createStatusDesc(s.config.NameSpace, "server_tag", mergeLabels(labels, prometheus.Labels{"tag": tag}))
+			metricCHan <- prometheus.MustNewConstMetric(serverTag, prometheus.GaugeValue, 1)
+		}
+
+		for _, player := range newStatus.Players {
+			// Games such as cs2 do not report steam ids, which would produce duplicate series.
+			if s.config.Players.Disabled || !player.steamID.Valid() {
+				continue
+			}
+
+			playerLabels := mergeLabels(labels, prometheus.Labels{"steam_id": player.steamID.String()})
+
+			if s.config.Players.InfoMetric {
+				info := createStatusDesc(s.config.NameSpace, "player_info", mergeLabels(playerLabels, s.config.Players.attributes(player)))
+				metricCHan <- prometheus.MustNewConstMetric(info, prometheus.GaugeValue, 1)
+			} else {
+				playerLabels = mergeLabels(playerLabels, s.config.Players.attributes(player))
 			}
 
-			slog.Debug("Got status", slog.String("map", newStatus.Map), slog.String("server", server.Name))
+			connected := createStatusDesc(s.config.NameSpace, "connected", playerLabels)
+			ping := createStatusDesc(s.config.NameSpace, "ping", playerLabels)
+			loss := createStatusDesc(s.config.NameSpace, "loss", playerLabels)
 
-			for _, player := range newStatus.Players {
-				connected := createStatusDesc(s.config.NameSpace, "connected", prometheus.Labels{"server": server.Name, "steam_id": player.steamID.String()})
-				ping := createStatusDesc(s.config.NameSpace, "ping", prometheus.Labels{"server": server.Name, "steam_id": player.steamID.String()})
-				loss := createStatusDesc(s.config.NameSpace, "loss", prometheus.Labels{"server": server.Name, "steam_id": player.steamID.String()})
+			metricCHan <- prometheus.MustNewConstMetric(connected, prometheus.GaugeValue, float64(player.online))
+			metricCHan <- prometheus.MustNewConstMetric(ping, prometheus.GaugeValue, float64(player.ping))
+			metricCHan <- prometheus.MustNewConstMetric(loss, prometheus.GaugeValue, float64(player.loss))
+		}
 
-				metricCHan <- prometheus.MustNewConstMetric(connected, prometheus.GaugeValue, float64(1))
-				metricCHan <- prometheus.MustNewConstMetric(ping, prometheus.GaugeValue, float64(player.ping))
-				metricCHan <- prometheus.MustNewConstMetric(loss, prometheus.GaugeValue, float64(player.loss))
+		var sessions, pings []float64
+
+		for _, player := range newStatus.Players {
+			if player.bot || player.state == playerStateConnecting {
+				continue
 			}
 
-			online := createStatusDesc(s.config.NameSpace, "online", prometheus.Labels{"server": server.Name})
-			playersCount := createStatusDesc(s.config.NameSpace, "players_count", prometheus.Labels{"server": server.Name})
-			playersLimit := createStatusDesc(s.config.NameSpace, "players_limit", prometheus.Labels{"server": server.Name})
-			playersHuman := createStatusDesc(s.config.NameSpace, "players_human", prometheus.Labels{"server": server.Name})
-			playersBots := createStatusDesc(s.config.NameSpace, "players_bots", prometheus.Labels{"server": server.Name})
-			edicts := createStatusDesc(s.config.NameSpace, "edicts", prometheus.Labels{"server": server.Name})
-			svVisibleMaxPlayers := createStatusDesc(s.config.NameSpace, "sv_visiblemaxplayers", prometheus.Labels{"server": server.Name})
-			sourceTV := createStatusDesc(s.config.NameSpace, "source_tv", prometheus.Labels{"server": server.Name})
-			cpu := createStatusDesc(s.config.NameSpace, "cpu", prometheus.Labels{"server": server.Name})
-			netIn := createStatusDesc(s.config.NameSpace, "net_in", prometheus.Labels{"server": server.Name})
-			netOut := createStatusDesc(s.config.NameSpace, "net_out", prometheus.Labels{"server": server.Name})
-			uptime := createStatusDesc(s.config.NameSpace, "uptime", prometheus.Labels{"server": server.Name})
-			maps := createStatusDesc(s.config.NameSpace, "maps", prometheus.Labels{"server": server.Name})
-			fps := createStatusDesc(s.config.NameSpace, "fps", prometheus.Labels{"server": server.Name})
-			players := createStatusDesc(s.config.NameSpace, "players", prometheus.Labels{"server": server.Name})
-			connects := createStatusDesc(s.config.NameSpace, "connects", prometheus.Labels{"server": server.Name})
-			svMaxUpdateRate := createStatusDesc(s.config.NameSpace, "sv_max_update_rate", prometheus.Labels{"server": server.Name})
-			mmVersion := createStatusDesc(s.config.NameSpace, "metamod_version", prometheus.Labels{
-				"server":          server.Name,
-				"metamod_version": newStatus.MMVersion,
-			})
-			smVersion := createStatusDesc(s.config.NameSpace, "sourcemod_version",
-				prometheus.Labels{"server": server.Name, "sourcemod_version": newStatus.SMVersion})
+			sessions = append(sessions, float64(player.online))
+			pings = append(pings, float64(player.ping))
+		}
 
-			metricCHan <- prometheus.MustNewConstMetric(online, prometheus.GaugeValue, 1)
-			metricCHan <- prometheus.MustNewConstMetric(playersCount, prometheus.GaugeValue, float64(len(newStatus.Players)))
-			metricCHan <- prometheus.MustNewConstMetric(playersLimit, prometheus.GaugeValue, float64(newStatus.PlayerLimit))
-			metricCHan <- prometheus.MustNewConstMetric(playersHuman, prometheus.GaugeValue, float64(newStatus.PlayersHumans))
-			metricCHan <- prometheus.MustNewConstMetric(playersBots, prometheus.GaugeValue, float64(newStatus.PlayersBots))
-			metricCHan <- prometheus.MustNewConstMetric(edicts, prometheus.GaugeValue, float64(newStatus.Edicts))
-			metricCHan <- prometheus.MustNewConstMetric(svVisibleMaxPlayers, prometheus.GaugeValue, float64(newStatus.SvVisibleMaxPlayers))
-
-			if newStatus.SourceTV {
-				metricCHan <- prometheus.MustNewConstMetric(sourceTV, prometheus.GaugeValue, 1)
+		sessionSeconds := createStatusDesc(s.config.NameSpace, "session_seconds", labels)
+		pingMilliseconds := createStatusDesc(s.config.NameSpace, "player_ping_milliseconds", labels)
+
+		metricCHan <- newConstHistogram(sessionSeconds, sessionBuckets, sessions)
+		metricCHan <- newConstHistogram(pingMilliseconds, pingBuckets, pings)
+
+		byState := map[string]int{playerStateActive: 0, playerStateSpawning: 0, playerStateConnecting: 0, "bot": 0}
+
+		for _, player := range newStatus.Players {
+			if player.bot {
+				byState["bot"]++
 			} else {
-				metricCHan <- prometheus.MustNewConstMetric(sourceTV, prometheus.GaugeValue, 0)
+				byState[player.state]++
 			}
+		}
 
-			metricCHan <- prometheus.MustNewConstMetric(cpu, prometheus.GaugeValue, newStatus.CPU)
-			metricCHan <- prometheus.MustNewConstMetric(netIn, prometheus.GaugeValue, newStatus.NetIn)
-			metricCHan <- prometheus.MustNewConstMetric(netOut, prometheus.GaugeValue, newStatus.NetOut)
-			metricCHan <- prometheus.MustNewConstMetric(uptime, prometheus.GaugeValue, float64(newStatus.Uptime))
-			metricCHan <- prometheus.MustNewConstMetric(maps, prometheus.GaugeValue, float64(newStatus.Maps))
-			metricCHan <- prometheus.MustNewConstMetric(fps, prometheus.GaugeValue, newStatus.FPS)
-			metricCHan <- prometheus.MustNewConstMetric(players, prometheus.GaugeValue, float64(newStatus.Player))
-			metricCHan <- prometheus.MustNewConstMetric(connects, prometheus.GaugeValue, float64(newStatus.Connects))
-			metricCHan <- prometheus.MustNewConstMetric(svMaxUpdateRate, prometheus.GaugeValue, newStatus.SvMaXUpdateRate)
-			metricCHan <- prometheus.MustNewConstMetric(mmVersion, prometheus.GaugeValue, 1)
-			metricCHan <- prometheus.MustNewConstMetric(smVersion, prometheus.GaugeValue, 1)
-		}(target)
-	}
+		for state, count := range byState {
+			playersByState := createStatusDesc(s.config.NameSpace, "players_by_state", mergeLabels(labels, prometheus.Labels{"state": state}))
+			metricCHan <- prometheus.MustNewConstMetric(playersByState, prometheus.GaugeValue, float64(count))
+		}
+
+		playersCount := createStatusDesc(s.config.NameSpace, "players_count", labels)
+		playersLimit := createStatusDesc(s.config.NameSpace, "players_limit", labels)
+		playersHuman := createStatusDesc(s.config.NameSpace, "players_human", labels)
+		playersBots := createStatusDesc(s.config.NameSpace, "players_bots", labels)
+		edicts := createStatusDesc(s.config.NameSpace, "edicts", labels)
+		svVisibleMaxPlayers := createStatusDesc(s.config.NameSpace, "sv_visiblemaxplayers", labels)
+		sourceTV := createStatusDesc(s.config.NameSpace, "source_tv", labels)
+		cpu := createStatusDesc(s.config.NameSpace, "cpu", labels)
+		netIn := createStatusDesc(s.config.NameSpace, "net_in", labels)
+		netOut := createStatusDesc(s.config.NameSpace, "net_out", labels)
+		uptime := createStatusDesc(s.config.NameSpace, "uptime", labels)
+		maps := createStatusDesc(s.config.NameSpace, "maps", labels)
+		fps := createStatusDesc(s.config.NameSpace, "fps", labels)
+		players := createStatusDesc(s.config.NameSpace, "players", labels)
+		connects := createStatusDesc(s.config.NameSpace, "connects", labels)
+		svMaxUpdateRate := createStatusDesc(s.config.NameSpace, "sv_max_update_rate", labels)
+		mmVersion := createStatusDesc(s.config.NameSpace, "metamod_version",
+			mergeLabels(labels, prometheus.Labels{"metamod_version": newStatus.MMVersion}))
+		smVersion := createStatusDesc(s.config.NameSpace, "sourcemod_version",
+			mergeLabels(labels, prometheus.Labels{"sourcemod_version": newStatus.SMVersion}))
+
+		metricCHan <- prometheus.MustNewConstMetric(playersCount, prometheus.GaugeValue, float64(len(newStatus.Players)))
+		metricCHan <- prometheus.MustNewConstMetric(playersLimit, prometheus.GaugeValue, float64(newStatus.PlayerLimit))
+		metricCHan <- prometheus.MustNewConstMetric(playersHuman, prometheus.GaugeValue, float64(newStatus.PlayersHumans))
+		metricCHan <- prometheus.MustNewConstMetric(playersBots, prometheus.GaugeValue, float64(newStatus.PlayersBots))
+		metricCHan <- prometheus.MustNewConstMetric(edicts, prometheus.GaugeValue, float64(newStatus.Edicts))
+
+		// The world is always an edict, so a zero count means the game does not print the edicts line.
+		if newStatus.Edicts > 0 {
+			edictsPeak := createStatusDesc(s.config.NameSpace, "edicts_peak", labels)
+			metricCHan <- prometheus.MustNewConstMetric(edictsPeak, prometheus.GaugeValue, float64(snap.maps.edictsPeak))
+		}
+
+		if newStatus.EdictsMax > 0 {
+			edictsMax := createStatusDesc(s.config.NameSpace, "edicts_max", labels)
+			metricCHan <- prometheus.MustNewConstMetric(edictsMax, prometheus.GaugeValue, float64(newStatus.EdictsMax))
+		}
+
+		metricCHan <- prometheus.MustNewConstMetric(svVisibleMaxPlayers, prometheus.GaugeValue, float64(newStatus.SvVisibleMaxPlayers))
+
+		if newStatus.SourceTV {
+			metricCHan <- prometheus.MustNewConstMetric(sourceTV, prometheus.GaugeValue, 1)
+
+			tvInfo := createStatusDesc(s.config.NameSpace, "sourcetv_info", mergeLabels(labels, prometheus.Labels{
+				"address":       newStatus.SourceTVAddress,
+				"local_address": newStatus.SourceTVLocal,
+			}))
+			tvDelay := createStatusDesc(s.config.NameSpace, "sourcetv_delay", labels)
+			tvSpectators := createStatusDesc(s.config.NameSpace, "sourcetv_spectators", labels)
+			tvRelays := createStatusDesc(s.config.NameSpace, "sourcetv_relays", labels)
+			tvRecording := createStatusDesc(s.config.NameSpace, "sourcetv_recording", labels)
 
-	waitGroup.Wait()
+			metricCHan <- prometheus.MustNewConstMetric(tvInfo, prometheus.GaugeValue, 1)
+			metricCHan <- prometheus.MustNewConstMetric(tvDelay, prometheus.GaugeValue, newStatus.SourceTVDelay)
+			metricCHan <- prometheus.MustNewConstMetric(tvSpectators, prometheus.GaugeValue, float64(newStatus.SourceTVSpectators))
+			metricCHan <- prometheus.MustNewConstMetric(tvRelays, prometheus.GaugeValue, float64(newStatus.SourceTVRelays))
+
+			if newStatus.SourceTVRecording {
+				metricCHan <- prometheus.MustNewConstMetric(tvRecording, prometheus.GaugeValue, 1)
+			} else {
+				metricCHan <- prometheus.MustNewConstMetric(tvRecording, prometheus.GaugeValue, 0)
+			}
+		} else {
+			metricCHan <- prometheus.MustNewConstMetric(sourceTV, prometheus.GaugeValue, 0)
+		}
+
+		metricCHan <- prometheus.MustNewConstMetric(cpu, prometheus.GaugeValue, newStatus.CPU)
+		metricCHan <- prometheus.MustNewConstMetric(netIn, prometheus.GaugeValue, newStatus.NetIn)
+		metricCHan <- prometheus.MustNewConstMetric(netOut, prometheus.GaugeValue, newStatus.NetOut)
+		metricCHan <- prometheus.MustNewConstMetric(uptime, prometheus.GaugeValue, float64(newStatus.Uptime))
+		metricCHan <- prometheus.MustNewConstMetric(maps, prometheus.GaugeValue, float64(newStatus.Maps))
+		metricCHan <- prometheus.MustNewConstMetric(fps, prometheus.GaugeValue, newStatus.FPS)
+		metricCHan <- prometheus.MustNewConstMetric(players, prometheus.GaugeValue, float64(newStatus.Player))
+		metricCHan <- prometheus.MustNewConstMetric(connects, prometheus.GaugeValue, float64(newStatus.Connects))
+		metricCHan <- prometheus.MustNewConstMetric(svMaxUpdateRate, prometheus.GaugeValue, newStatus.SvMaXUpdateRate)
+		metricCHan <- prometheus.MustNewConstMetric(mmVersion, prometheus.GaugeValue, 1)
+		metricCHan <- prometheus.MustNewConstMetric(smVersion, prometheus.GaugeValue, 1)
+	}
 
 	return nil
 }
@@ -415,19 +739,35 @@ func parseConnected(d string) (time.Duration, error) {
 	return dur, errors.Wrap(parseErr, "Failed to parse connected time string")
 }
 
-func fetchStatus(conn *rcon.RemoteConsole) (*status, error) {
-	body, errExec := conn.Exec("status;stats;sv_maxupdaterate;sm version;meta version;sv_visiblemaxplayers")
+func fetchStatus(ctx context.Context, conn *rconConn) (*status, error) {
+	parser := newStatusParser(conn.target.Game)
 
+	body, errExec := conn.exec(ctx, parser.command)
 	if errExec != nil {
 		return nil, errors.Wrap(errExec, "Failed to execute rcon status command")
 	}
 
-	parser := newStatusParser()
+	newStatus, errStatus := parser.parse(body)
+	if errStatus != nil {
+		return nil, errStatus
+	}
+
+	if newStatus.UnmatchedLines > 0 || len(newStatus.MissingSections) > 0 {
+		slog.Debug("Status output was not fully parsed", slog.String("server", conn.target.Name),
+			slog.Any("missing_sections", newStatus.MissingSections), slog.Int("unmatched_lines", newStatus.UnmatchedLines),
+			slog.Any("unmatched_sample", newStatus.UnmatchedSample))
+	}
 
-	return parser.parse(body)
+	return newStatus, nil
 }
 
 type statusParser struct {
+	reHostname       *regexp.Regexp
+	reVersion        *regexp.Regexp
+	reAddress        *regexp.Regexp
+	reSteamID        *regexp.Regexp
+	reAccount        *regexp.Regexp
+	reTags           *regexp.Regexp
 	reMapName        *regexp.Regexp
 	rePlayers        *regexp.Regexp
 	rePlayer         *regexp.Regexp
@@ -438,46 +778,116 @@ type statusParser struct {
 	reMMVersion      *regexp.Regexp
 	reSMVersion      *regexp.Regexp
 	reSourceTV       *regexp.Regexp
+	reTVTotal        *regexp.Regexp
+	reTVRecording    *regexp.Regexp
+	// reIgnore matches known lines which carry nothing of interest, so they are not counted as unmatched.
+	reIgnore *regexp.Regexp
+	sections []string
+	// command is the rcon command producing the output parsed, limited to the commands the game supports.
+	command string
 }
 
-func newStatusParser() statusParser {
-	return statusParser{
-		reSourceTV:       regexp.MustCompile(`^sourcetv:\s+(?P<stv>74.91.117.2:27015),`),
-		reMMVersion:      regexp.MustCompile(`^\s+Metamod:Source\sversion\s+(?P<mm_version>.+?)$`),
-		reSMVersion:      regexp.MustCompile(`^\s+SourceMod\sVersion:\s(?P<sm_version>.+?)$`),
-		reRate:           regexp.MustCompile(`^"sv_maxupdaterate" = "(?P<rate>\d+)"$`),
-		reStats:          regexp.MustCompile(`^(?P<cpu>\d{1,3}\.\d{1,2})\s+(?P<net_in>\d{1,3}\.\d{1,2})\s+(?P<net_out>\d{1,3}\.\d{1,2})\s+(?P<uptime>\d+)\s+(?P<maps>\d+)\s+(?P<fps>\d{1,3}\.\d{1,2})\s+(?P<players>\d+)\s+(?P<connects>\d+)(\s+)?$`),
-		reVisiblePlayers: regexp.MustCompile(`^"sv_visiblemaxplayers" = "(?P<sv_visiblemaxplayers>\d+)"`),
-		reMapName:        regexp.MustCompile(`^map\s{5}:\s(?P<map_name>.+?)\sat.+?$`),
-		reEdicts:         regexp.MustCompile(`^edicts\s+:\s+(?P<edicts>\d+)\sused.+?$`),
-		rePlayers:        regexp.MustCompile(`^players\s+:\s+(?P<humans>\d+)\s+humans,\s+(?P<bots>\d+)\s+bots\s+\((?P<max>\d+)\smax\)$`),
-		rePlayer:         regexp.MustCompile(`^#\s{1,6}(?P<id>\d{1,6})\s"(?P<name>.+?)"\s+(?P<sid>\[U:\d:\d{1,10}])\s{1,8}(?P<time>\d{1,3}:\d{2}(:\d{2})?)\s+(?P<ping>\d{1,4})\s{1,8}(?P<loss>\d{1,3})\s(spawning|active)\s+(?P<ip>\d+\.\d+\.\d+\.\d+:\d+)$`),
-	}
-}
+// Sections of the combined command output, see statusParser.sections.
+const (
+	sectionMap      = "map"
+	sectionPlayers  = "players"
+	sectionEdicts   = "edicts"
+	sectionStats    = "stats"
+	sectionRate     = "rate"
+	sectionVersions = "versions"
+)
+
+// Histogram buckets of the per server player distributions.
+var (
+	sessionBuckets = []float64{60, 300, 600, 1800, 3600, 7200, 14400, 28800} //nolint:gochecknoglobals
+	pingBuckets    = []float64{25, 50, 75, 100, 150, 200, 300, 500}          //nolint:gochecknoglobals
+)
+
+// unmatchedSampleSize is the number of unmatched lines kept for logging.
+const unmatchedSampleSize = 5
+
+var (
+	errParse       = errors.New("failed to parse status")
+	errEmptyStatus = errors.Wrap(errParse, "status response did not contain a map")
+)
 
 func (p *statusParser) parse(body string) (*status, error) {
 	newStatus := status{}
+	found := map[string]bool{}
 
 	for _, line := range strings.Split(body, "\n") {
-		match := p.reMapName.FindStringSubmatch(line)
+		line = strings.TrimRight(line, "\r")
+
+		match := p.reHostname.FindStringSubmatch(line)
+		if match != nil {
+			newStatus.Hostname = labelValue(match[1])
+
+			continue
+		}
+
+		match = p.reVersion.FindStringSubmatch(line)
+		if match != nil {
+			newStatus.Version = group(p.reVersion, match, "version")
+			newStatus.Build = group(p.reVersion, match, "build")
+			newStatus.Secure = group(p.reVersion, match, "secure") == "secure"
+
+			continue
+		}
+
+		match = p.reAddress.FindStringSubmatch(line)
+		if match != nil {
+			newStatus.Address = match[1]
+
+			continue
+		}
+
+		match = p.reSteamID.FindStringSubmatch(line)
+		if match != nil {
+			newStatus.ServerSteamID = group(p.reSteamID, match, "sid")
+
+			continue
+		}
+
+		match = p.reAccount.FindStringSubmatch(line)
+		if match != nil {
+			newStatus.Account = labelValue(match[1])
+
+			continue
+		}
+
+		match = p.reTags.FindStringSubmatch(line)
+		if match != nil {
+			// Most games separate tags with commas, gmod uses spaces.
+			newStatus.Tags = strings.FieldsFunc(labelValue(match[1]), func(r rune) bool {
+				return r == ',' || unicode.IsSpace(r)
+			})
+
+			continue
+		}
+
+		match = p.reMapName.FindStringSubmatch(line)
 		if match != nil {
-			newStatus.Map = match[1]
+			found[sectionMap] = true
+			newStatus.Map = labelValue(group(p.reMapName, match, "map_name"))
 
 			continue
 		}
 
 		match = p.reEdicts.FindStringSubmatch(line)
 		if match != nil {
-			newStatus.Edicts = toIntDefault(match[1], 0)
+			found[sectionEdicts] = true
+			newStatus.Edicts = toIntDefault(group(p.reEdicts, match, "edicts"), 0)
+			newStatus.EdictsMax = toIntDefault(group(p.reEdicts, match, "max"), 0)
 
 			continue
 		}
 
 		match = p.rePlayers.FindStringSubmatch(line)
 		if match != nil {
-			newStatus.PlayersHumans = toIntDefault(match[1], 0)
-			newStatus.PlayersBots = toIntDefault(match[2], 0)
-			newStatus.PlayerLimit = toIntDefault(match[3], 32)
+			found[sectionPlayers] = true
+			newStatus.PlayersHumans = toIntDefault(group(p.rePlayers, match, "humans"), 0)
+			newStatus.PlayersBots = toIntDefault(group(p.rePlayers, match, "bots"), 0)
+			newStatus.PlayerLimit = toIntDefault(group(p.rePlayers, match, "max"), 32)
 
 			continue
 		}
@@ -491,20 +901,22 @@ func (p *statusParser) parse(body string) (*status, error) {
 
 		match = p.reStats.FindStringSubmatch(line)
 		if match != nil {
-			newStatus.CPU = toFloat64Default(match[1], 0.0)
-			newStatus.NetIn = toFloat64Default(match[2], 0.0)
-			newStatus.NetOut = toFloat64Default(match[3], 0.0)
-			newStatus.Uptime = toIntDefault(match[4], 0)
-			newStatus.Maps = toIntDefault(match[5], 0)
-			newStatus.FPS = toFloat64Default(match[6], 0.0)
-			newStatus.Player = toIntDefault(match[7], 0)
-			newStatus.Connects = toIntDefault(match[8], 0)
+			found[sectionStats] = true
+			newStatus.CPU = toFloat64Default(group(p.reStats, match, "cpu"), 0.0)
+			newStatus.NetIn = toFloat64Default(group(p.reStats, match, "net_in"), 0.0)
+			newStatus.NetOut = toFloat64Default(group(p.reStats, match, "net_out"), 0.0)
+			newStatus.Uptime = toIntDefault(group(p.reStats, match, "uptime"), 0)
+			newStatus.Maps = toIntDefault(group(p.reStats, match, "maps"), 0)
+			newStatus.FPS = toFloat64Default(group(p.reStats, match, "fps"), 0.0)
+			newStatus.Player = toIntDefault(group(p.reStats, match, "players"), 0)
+			newStatus.Connects = toIntDefault(group(p.reStats, match, "connects"), 0)
 
 			continue
 		}
 
 		match = p.reMMVersion.FindStringSubmatch(line)
 		if match != nil {
+			found[sectionVersions] = true
 			newStatus.MMVersion = match[1]
 
 			continue
@@ -512,6 +924,7 @@ func (p *statusParser) parse(body string) (*status, error) {
 
 		match = p.reSMVersion.FindStringSubmatch(line)
 		if match != nil {
+			found[sectionVersions] = true
 			newStatus.SMVersion = match[1]
 
 			continue
@@ -519,6 +932,7 @@ func (p *statusParser) parse(body string) (*status, error) {
 
 		match = p.reRate.FindStringSubmatch(line)
 		if match != nil {
+			found[sectionRate] = true
 			newStatus.SvMaXUpdateRate = toFloat64Default(match[1], 0)
 
 			continue
@@ -527,6 +941,29 @@ func (p *statusParser) parse(body string) (*status, error) {
 		match = p.reSourceTV.FindStringSubmatch(line)
 		if match != nil {
 			newStatus.SourceTV = true
+			newStatus.SourceTVAddress = group(p.reSourceTV, match, "addr")
+
+			// gotv only reports the port it is listening on.
+			if port := group(p.reSourceTV, match, "port"); port != "" {
+				newStatus.SourceTVAddress = net.JoinHostPort("", port)
+			}
+
+			newStatus.SourceTVDelay = toFloat64Default(group(p.reSourceTV, match, "delay"), 0)
+			newStatus.SourceTVLocal = group(p.reSourceTV, match, "local")
+
+			continue
+		}
+
+		match = p.reTVTotal.FindStringSubmatch(line)
+		if match != nil {
+			newStatus.SourceTVSpectators = toIntDefault(match[1], 0)
+			newStatus.SourceTVRelays = toIntDefault(match[2], 0)
+
+			continue
+		}
+
+		if p.reTVRecording.MatchString(line) {
+			newStatus.SourceTVRecording = true
 
 			continue
 		}
@@ -534,32 +971,86 @@ func (p *statusParser) parse(body string) (*status, error) {
 		match = p.rePlayer.FindStringSubmatch(line)
 		if match != nil {
 			newStatusPlayer := statusPlayer{}
-			newStatusPlayer.steamID = steamid.New(match[3])
+			newStatusPlayer.userID = toIntDefault(group(p.rePlayer, match, "userid"), 0)
+			newStatusPlayer.name = group(p.rePlayer, match, "name")
+			newStatusPlayer.bot = group(p.rePlayer, match, "sid") == "BOT" || group(p.rePlayer, match, "time") == "BOT"
+			newStatusPlayer.steamID = parseSteamID(group(p.rePlayer, match, "sid"))
+
+			// GoldSrc does not report a state.
+			newStatusPlayer.state = group(p.rePlayer, match, "state")
+			if newStatusPlayer.state == "" {
+				newStatusPlayer.state = playerStateActive
+			}
 
-			duration, errDur := parseConnected(match[4])
+			duration, errDur := parseConnected(group(p.rePlayer, match, "time"))
 			if errDur != nil {
 				duration = time.Duration(0)
 			}
 
 			newStatusPlayer.online = int(duration.Seconds())
-			newStatusPlayer.ping = toIntDefault(match[6], 0)
-			newStatusPlayer.loss = toIntDefault(match[7], 0)
-			newStatusPlayer.address = match[9]
-			pcs := strings.Split(newStatusPlayer.address, ":")
-			newStatusPlayer.ip = pcs[0]
-
-			port, errPort := strconv.ParseInt(pcs[1], 10, 64)
-			if errPort != nil {
-				port = 20000
+			newStatusPlayer.ping = toIntDefault(group(p.rePlayer, match, "ping"), 0)
+			newStatusPlayer.loss = toIntDefault(group(p.rePlayer, match, "loss"), 0)
+			newStatusPlayer.address = group(p.rePlayer, match, "ip")
+
+			if newStatusPlayer.address != "" {
+				host, port, errSplit := net.SplitHostPort(newStatusPlayer.address)
+				if errSplit != nil {
+					slog.Debug("Failed to parse player address", slog.String("address", newStatusPlayer.address))
+
+					host = newStatusPlayer.address
+				}
+
+				newStatusPlayer.ip = host
+				newStatusPlayer.port = toIntDefault(port, 20000)
 			}
 
-			newStatusPlayer.port = int(port)
 			newStatus.Players = append(newStatus.Players, newStatusPlayer)
+
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" || p.reIgnore.MatchString(line) {
+			continue
+		}
+
+		newStatus.UnmatchedLines++
+
+		if len(newStatus.UnmatchedSample) < unmatchedSampleSize {
+			newStatus.UnmatchedSample = append(newStatus.UnmatchedSample, line)
 		}
 	}
 
+	for _, section := range p.sections {
+		if !found[section] {
+			newStatus.MissingSections = append(newStatus.MissingSections, section)
+		}
+	}
+
+	if newStatus.Map == "" {
+		return nil, errEmptyStatus
+	}
+
 	return &newStatus, nil
 }
 
+// group returns the named capture group of the match, or an empty string when the regex does not define it.
+func group(re *regexp.Regexp, match []string, name string) string {
+	idx := re.SubexpIndex(name)
+	if idx < 0 || idx >= len(match) {
+		return ""
+	}
+
+	return match[idx]
+}
+
+// parseSteamID parses the steam id column of a player row, which some builds print without brackets.
+func parseSteamID(value string) steamid.SteamID {
+	if strings.HasPrefix(value, "U:") {
+		value = "[" + value + "]"
+	}
+
+	return steamid.New(value)
+}
+
 func init() {
 }
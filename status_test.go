@@ -1,14 +1,25 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/leighmacdonald/steamid/v4/steamid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/stretchr/testify/require"
 )
 
 func TestParseStatus(t *testing.T) {
-	parser := newStatusParser()
+	parser := newStatusParser(gameTF2)
 
 	result, parseErr := parser.parse(`hostname: Kittyland Server
 version : 7961495/24 7961495 secure
@@ -29,21 +40,274 @@ edicts  : 781 used of 2048 max
 #    765 "Detrim"            [U:1:155803057]     19:22       80    0 active 10.0.0.5:27005
 #    720 "viciousbeatmaker"  [U:1:126610924]      1:36:10    72    0 active 10.0.0.6:27005
 #    684 "smeasly"           [U:1:68453084]       2:51:15    33    0 active 10.0.0.7:27005
+SourceTV Master "SourceTV", delay 0
+IP 10.20.30.40:27015, Online 02:51:15, Version 24 (Linux)
+Game Time 10:23, Mod "tf", Map "pl_upward", Players 8
+Local Slots 32, Spectators 1, Proxies 0
+Total Slots 64, Spectators 4, Proxies 1
+Recording to "demos/auto-20261017-pl_upward.dem", length 10:23.
 `)
 
 	require.NoError(t, parseErr)
+	require.Equal(t, "Kittyland Server", result.Hostname)
+	require.Equal(t, "7961495/24", result.Version)
+	require.Equal(t, "7961495", result.Build)
+	require.True(t, result.Secure)
+	require.Equal(t, "1.2.33.44:27015", result.Address)
+	require.Equal(t, "[G:1:411111]", result.ServerSteamID)
+	require.Equal(t, "not logged in", result.Account)
+	require.Equal(t, []string{"nocrits", "nodmgspread", "payload", "uncletopia"}, result.Tags)
 	require.Equal(t, 7, result.PlayersHumans)
 	require.Equal(t, 1, result.PlayersBots)
 	require.Equal(t, 781, result.Edicts)
+	require.Equal(t, 2048, result.EdictsMax)
 	require.Equal(t, "pl_upward", result.Map)
 	require.Equal(t, 33, result.PlayerLimit)
+	require.True(t, result.SourceTV)
+	require.Equal(t, "10.20.30.40:27015", result.SourceTVAddress)
+	require.Equal(t, "10.20.30.40:27016", result.SourceTVLocal)
+	require.InDelta(t, 0.0, result.SourceTVDelay, 0)
+	require.Equal(t, 4, result.SourceTVSpectators)
+	require.Equal(t, 1, result.SourceTVRelays)
+	require.True(t, result.SourceTVRecording)
 	require.Equal(t, []statusPlayer{
-		{online: 303, ping: 55, loss: 0, address: "10.0.0.1:27005", port: 27005, ip: "10.0.0.1", steamID: steamid.New("[U:1:102426391]")},
-		{online: 293, ping: 120, loss: 0, address: "10.0.0.2:27005", port: 27005, ip: "10.0.0.2", steamID: steamid.New("[U:1:279850548]")},
-		{online: 274, ping: 93, loss: 0, address: "10.0.0.3:36973", port: 36973, ip: "10.0.0.3", steamID: steamid.New("[U:1:1121894230]")},
-		{online: 2230, ping: 87, loss: 0, address: "10.0.0.4:27005", port: 27005, ip: "10.0.0.4", steamID: steamid.New("[U:1:859279805]")},
-		{online: 1162, ping: 80, loss: 0, address: "10.0.0.5:27005", port: 27005, ip: "10.0.0.5", steamID: steamid.New("[U:1:155803057]")},
-		{online: 5770, ping: 72, loss: 0, address: "10.0.0.6:27005", port: 27005, ip: "10.0.0.6", steamID: steamid.New("[U:1:126610924]")},
-		{online: 10275, ping: 33, loss: 0, address: "10.0.0.7:27005", port: 27005, ip: "10.0.0.7", steamID: steamid.New("[U:1:68453084]")},
+		{userID: 2, name: "Uncletopia | Seattle | 1 | All ", state: "active", bot: true, steamID: steamid.New("BOT")},
+		{userID: 774, name: "Dred", state: "active", online: 303, ping: 55, loss: 0, address: "10.0.0.1:27005", port: 27005, ip: "10.0.0.1", steamID: steamid.New("[U:1:102426391]")},
+		{userID: 775, name: "smiley", state: "active", online: 293, ping: 120, loss: 0, address: "10.0.0.2:27005", port: 27005, ip: "10.0.0.2", steamID: steamid.New("[U:1:279850548]")},
+		{userID: 776, name: "Eve From Summertime Saga", state: "active", online: 274, ping: 93, loss: 0, address: "10.0.0.3:36973", port: 36973, ip: "10.0.0.3", steamID: steamid.New("[U:1:1121894230]")},
+		{userID: 753, name: "APPLEHACK FATMAGIC RELATIVE", state: "active", online: 2230, ping: 87, loss: 0, address: "10.0.0.4:27005", port: 27005, ip: "10.0.0.4", steamID: steamid.New("[U:1:859279805]")},
+		{userID: 765, name: "Detrim", state: "active", online: 1162, ping: 80, loss: 0, address: "10.0.0.5:27005", port: 27005, ip: "10.0.0.5", steamID: steamid.New("[U:1:155803057]")},
+		{userID: 720, name: "viciousbeatmaker", state: "active", online: 5770, ping: 72, loss: 0, address: "10.0.0.6:27005", port: 27005, ip: "10.0.0.6", steamID: steamid.New("[U:1:126610924]")},
+		{userID: 684, name: "smeasly", state: "active", online: 10275, ping: 33, loss: 0, address: "10.0.0.7:27005", port: 27005, ip: "10.0.0.7", steamID: steamid.New("[U:1:68453084]")},
 	}, result.Players)
 }
+
+func TestParseStatusEmpty(t *testing.T) {
+	parser := newStatusParser(gameTF2)
+
+	_, parseErr := parser.parse("Unknown command \"status\"\n")
+	require.ErrorIs(t, parseErr, errParse)
+}
+
+func TestParseStatusSourceTVInactive(t *testing.T) {
+	parser := newStatusParser(gameTF2)
+
+	result, parseErr := parser.parse(`map     : pl_upward at: 0 x, 0 y, 0 z
+SourceTV not active.
+`)
+	require.NoError(t, parseErr)
+	require.False(t, result.SourceTV)
+	require.False(t, result.SourceTVRecording)
+}
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// goldenPlayer exports the fields of statusPlayer so they can be written to golden files.
+type goldenPlayer struct {
+	UserID  int    `json:"userid"`
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	Bot     bool   `json:"bot"`
+	SteamID string `json:"steam_id"`
+	Address string `json:"address"`
+	IP      string `json:"ip"`
+	Port    int    `json:"port"`
+	Online  int    `json:"online"`
+	Ping    int    `json:"ping"`
+	Loss    int    `json:"loss"`
+}
+
+type goldenStatus struct {
+	status
+
+	Players []goldenPlayer
+}
+
+func newGoldenStatus(result *status) goldenStatus {
+	golden := goldenStatus{status: *result, Players: []goldenPlayer{}}
+
+	for _, player := range result.Players {
+		golden.Players = append(golden.Players, goldenPlayer{
+			UserID:  player.userID,
+			Name:    player.name,
+			State:   player.state,
+			Bot:     player.bot,
+			SteamID: string(player.steamID.Steam3()),
+			Address: player.address,
+			IP:      player.ip,
+			Port:    player.port,
+			Online:  player.online,
+			Ping:    player.ping,
+			Loss:    player.loss,
+		})
+	}
+
+	return golden
+}
+
+// corpusGame returns the game of a corpus file, named either <game>.txt or <game>_<case>.txt.
+func corpusGame(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), ".txt")
+	game, _, _ := strings.Cut(name, "_")
+
+	return game
+}
+
+// TestParseStatusCorpus parses every output in testdata/status using the parser of its game and compares the
+// result with the matching json file. Run with -update to regenerate the json files.
+func TestParseStatusCorpus(t *testing.T) {
+	paths, errGlob := filepath.Glob(filepath.Join("testdata", "status", "*.txt"))
+	require.NoError(t, errGlob)
+
+	for _, path := range paths {
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".txt"), func(t *testing.T) {
+			game := corpusGame(path)
+			require.Contains(t, games, game)
+
+			body, errRead := os.ReadFile(path)
+			require.NoError(t, errRead)
+
+			parser := newStatusParser(game)
+
+			result, errParse := parser.parse(string(body))
+			require.NoError(t, errParse)
+
+			actual, errJSON := json.MarshalIndent(newGoldenStatus(result), "", "  ")
+			require.NoError(t, errJSON)
+
+			goldenPath := strings.TrimSuffix(path, ".txt") + ".json"
+
+			if *update {
+				require.NoError(t, os.WriteFile(goldenPath, append(actual, '\n'), 0o600))
+			}
+
+			expected, errGolden := os.ReadFile(goldenPath)
+			require.NoError(t, errGolden)
+			require.JSONEq(t, string(expected), string(actual))
+		})
+	}
+}
+
+// FuzzStatusParser checks that no input can crash any of the game parsers. Seeded with the corpus.
+func FuzzStatusParser(f *testing.F) {
+	paths, errGlob := filepath.Glob(filepath.Join("testdata", "status", "*.txt"))
+	require.NoError(f, errGlob)
+
+	for _, path := range paths {
+		body, errRead := os.ReadFile(path)
+		require.NoError(f, errRead)
+
+		f.Add(string(body))
+	}
+
+	parsers := make([]statusParser, len(games))
+	for idx, game := range games {
+		parsers[idx] = newStatusParser(game)
+	}
+
+	f.Fuzz(func(t *testing.T, body string) {
+		for _, parser := range parsers {
+			result, errStatus := parser.parse(body)
+			if errStatus != nil {
+				require.ErrorIs(t, errStatus, errParse)
+				require.Nil(t, result)
+
+				continue
+			}
+
+			require.NotEmpty(t, result.Map)
+		}
+	})
+}
+
+// scrape returns the text exposition of the collectors.
+func scrape(t *testing.T, collectors ...CollectorHandler) string {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(newRootCollector(context.Background(), collectors...)))
+
+	recorder := httptest.NewRecorder()
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	return recorder.Body.String()
+}
+
+func TestStatusCollectorPlayers(t *testing.T) {
+	conf := newConfig()
+	conf.Targets = []Target{{Name: "a", Protocol: protocolRCON, Game: gameTF2}}
+
+	body, errRead := os.ReadFile(filepath.Join("testdata", "status", "tf2_bots.txt"))
+	require.NoError(t, errRead)
+
+	parser := newStatusParser(gameTF2)
+
+	result, errParse := parser.parse(string(body))
+	require.NoError(t, errParse)
+
+	cache := newStatusCache()
+	cache.update("a", result, nil, time.Now())
+
+	text := scrape(t, newStatusCollector(conf, cache))
+	require.Contains(t, text, `srcds_status_connected{server="a",steam_id="76561197961265931"} 345`)
+	require.Contains(t, text, `srcds_status_players_by_state{server="a",state="bot"} 3`)
+	require.Contains(t, text, `srcds_status_players_by_state{server="a",state="connecting"} 1`)
+	require.Contains(t, text, `srcds_status_session_seconds_bucket{server="a",le="300"} 1`)
+	require.Contains(t, text, `srcds_status_session_seconds_count{server="a"} 2`)
+	require.Contains(t, text, `srcds_status_player_ping_milliseconds_bucket{server="a",le="200"} 2`)
+	require.Contains(t, text, `srcds_status_player_ping_milliseconds_sum{server="a"} 231`)
+
+	conf.Players = PlayerConfig{Labels: []string{playerLabelName}, InfoMetric: true}
+	text = scrape(t, newStatusCollector(conf, cache))
+	require.Contains(t, text, `srcds_status_player_info{name="playing",server="a",steam_id="76561197961265931"} 1`)
+
+	conf.Players = PlayerConfig{Disabled: true}
+	require.NotContains(t, scrape(t, newStatusCollector(conf, cache)), "srcds_status_connected{")
+}
+
+func TestStatusCollectorEdicts(t *testing.T) {
+	conf := newConfig()
+	conf.Targets = []Target{{Name: "a", Protocol: protocolRCON, Game: gameTF2}}
+
+	cache := newStatusCache()
+	cache.update("a", &status{Map: "pl_upward", Edicts: 900}, nil, time.Now())
+
+	text := scrape(t, newStatusCollector(conf, cache))
+	require.Contains(t, text, `srcds_status_edicts_peak{server="a"} 900`)
+	require.NotContains(t, text, "srcds_status_edicts_max{")
+
+	cache.update("a", &status{Map: "pl_upward", Edicts: 800, EdictsMax: 2048}, nil, time.Now())
+
+	text = scrape(t, newStatusCollector(conf, cache))
+	require.Contains(t, text, `srcds_status_edicts_peak{server="a"} 900`)
+	require.Contains(t, text, `srcds_status_edicts_max{server="a"} 2048`)
+}
+
+func TestStatusCollectorInvalidUTF8(t *testing.T) {
+	conf := newConfig()
+	conf.Targets = []Target{{Name: "a", Protocol: protocolRCON, Game: gameTF2}}
+
+	body, errRead := os.ReadFile(filepath.Join("testdata", "status", "tf2.txt"))
+	require.NoError(t, errRead)
+
+	body = []byte(strings.Replace(string(body), "Uncletopia | Seattle | 1 | All Maps", "caf\xc3", 1))
+	body = []byte(strings.Replace(string(body), "uncletopia", "uncle\xfftopia", 1))
+	body = []byte(strings.Replace(string(body), "koth_product_final", "koth_\xe2\x82", 1))
+
+	parser := newStatusParser(gameTF2)
+
+	result, errParse := parser.parse(string(body))
+	require.NoError(t, errParse)
+	require.Equal(t, "caf�", result.Hostname)
+
+	cache := newStatusCache()
+	cache.update("a", result, nil, time.Now().Add(-time.Minute))
+	cache.update("a", result, nil, time.Now())
+
+	text := scrape(t, newStatusCollector(conf, cache))
+	require.Contains(t, text, `hostname="caf`+"�"+`"`)
+	require.Contains(t, text, `srcds_server_tag{server="a",tag="uncle`+"�"+`topia"} 1`)
+	require.Contains(t, text, `srcds_map_info{map="koth_`+"�"+`",server="a"} 1`)
+	require.Contains(t, text, `srcds_map_player_seconds_total{map="koth_`+"�"+`",server="a"}`)
+}
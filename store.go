@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	storeOpenTimeout = time.Second * 5
+	// storePruneInterval is how often the sightings older than the largest window are removed.
+	storePruneInterval = time.Hour
+)
+
+var errStoreOpen = errors.New("failed to open player store")
+
+// storeTotalKey holds the count of players ever recorded in each target bucket. It can not collide with the
+// 8 byte steam id keys.
+var storeTotalKey = []byte("total") //nolint:gochecknoglobals
+
+// storeWindows are the rolling windows the unique and new player counts are reported for, in ascending order.
+var storeWindows = []struct { //nolint:gochecknoglobals
+	name     string
+	duration time.Duration
+}{
+	{name: "24h", duration: time.Hour * 24},
+	{name: "7d", duration: time.Hour * 24 * 7},
+}
+
+// playerSummary holds the counts computed from the sightings of a single target.
+type playerSummary struct {
+	unique map[string]int
+	new    map[string]int
+	total  uint64
+}
+
+// playerStore records when each player was first and last seen on each target in a bbolt database, so
+// that unique player counts over days can be reported and survive restarts. Each target has its own
+// bucket keyed by the 64 bit steam id, holding the first and last seen unix timestamps. Players not seen
+// within the largest window are forgotten, bounding the size of the database.
+type playerStore struct {
+	db *bolt.DB
+
+	mu     sync.RWMutex
+	config *config
+	// pruned holds the time the sightings of each target were last pruned.
+	pruned map[string]time.Time
+}
+
+func openPlayerStore(config *config) (*playerStore, error) {
+	database, errOpen := bolt.Open(config.StorePath, 0o600, &bolt.Options{Timeout: storeOpenTimeout})
+	if errOpen != nil {
+		return nil, errors.Join(errOpen, errStoreOpen)
+	}
+
+	slog.Info("Opened player store", slog.String("path", config.StorePath))
+
+	return &playerStore{config: config, db: database, pruned: map[string]time.Time{}}, nil
+}
+
+func (s *playerStore) Name() string {
+	return "store"
+}
+
+func (s *playerStore) close() {
+	if errClose := s.db.Close(); errClose != nil {
+		slog.Error("Failed to close player store", slog.String("error", errClose.Error()))
+	}
+}
+
+// reload replaces the config. Sightings of removed targets are kept, so they are still known if the target
+// is added back. Changes to the store path require a restart.
+func (s *playerStore) reload(config *config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.config = config
+
+	for name := range s.pruned {
+		if !slices.ContainsFunc(config.Targets, func(target Target) bool { return target.Name == name }) {
+			delete(s.pruned, name)
+		}
+	}
+}
+
+// record stores a sighting of every human player with a steam id. Sightings older than the largest window
+// are removed in the same transaction, at most once per storePruneInterval.
+func (s *playerStore) record(name string, newStatus *status, seen time.Time) error {
+	s.mu.Lock()
+	prune := seen.Sub(s.pruned[name]) >= storePruneInterval
+	s.mu.Unlock()
+
+	errUpdate := s.db.Update(func(tx *bolt.Tx) error {
+		bucket, errBucket := tx.CreateBucketIfNotExists([]byte(name))
+		if errBucket != nil {
+			return errBucket
+		}
+
+		total := storeTotal(bucket)
+
+		for _, player := range newStatus.Players {
+			if player.bot || !player.steamID.Valid() {
+				continue
+			}
+
+			key := binary.BigEndian.AppendUint64(nil, uint64(player.steamID.Int64())) //nolint:gosec
+			firstSeen := seen
+
+			if value := bucket.Get(key); len(value) == 16 {
+				firstSeen = time.Unix(int64(binary.BigEndian.Uint64(value)), 0) //nolint:gosec
+			} else {
+				total++
+			}
+
+			value := binary.BigEndian.AppendUint64(nil, uint64(firstSeen.Unix())) //nolint:gosec
+			value = binary.BigEndian.AppendUint64(value, uint64(seen.Unix()))     //nolint:gosec
+
+			if errPut := bucket.Put(key, value); errPut != nil {
+				return errPut
+			}
+		}
+
+		if errPut := bucket.Put(storeTotalKey, binary.BigEndian.AppendUint64(nil, total)); errPut != nil {
+			return errPut
+		}
+
+		if prune {
+			return pruneSightings(bucket, seen.Add(-storeWindows[len(storeWindows)-1].duration))
+		}
+
+		return nil
+	})
+	if errUpdate != nil {
+		return errUpdate
+	}
+
+	if prune {
+		s.mu.Lock()
+		s.pruned[name] = seen
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+// storeTotal returns the count of players ever recorded in the bucket. Buckets written before the count was
+// kept start from the number of sightings.
+func storeTotal(bucket *bolt.Bucket) uint64 {
+	if value := bucket.Get(storeTotalKey); len(value) == 8 {
+		return binary.BigEndian.Uint64(value)
+	}
+
+	return uint64(bucket.Stats().KeyN) //nolint:gosec
+}
+
+// pruneSightings removes the players last seen before the cutoff.
+func pruneSightings(bucket *bolt.Bucket, cutoff time.Time) error {
+	cursor := bucket.Cursor()
+
+	for key, value := cursor.First(); key != nil; {
+		if len(key) != 8 || len(value) != 16 || int64(binary.BigEndian.Uint64(value[8:])) >= cutoff.Unix() { //nolint:gosec
+			key, value = cursor.Next()
+
+			continue
+		}
+
+		if errDelete := cursor.Delete(); errDelete != nil {
+			return errDelete
+		}
+
+		// Deleting moves the cursor onto the following key.
+		key, value = cursor.Seek(key)
+	}
+
+	return nil
+}
+
+// summarise counts the players of the bucket seen, and first seen, within each window.
+func summarise(bucket *bolt.Bucket, now time.Time) playerSummary {
+	summary := playerSummary{unique: map[string]int{}, new: map[string]int{}, total: storeTotal(bucket)}
+
+	for _, window := range storeWindows {
+		summary.unique[window.name] = 0
+		summary.new[window.name] = 0
+	}
+
+	_ = bucket.ForEach(func(key []byte, value []byte) error {
+		if len(key) != 8 || len(value) != 16 {
+			return nil
+		}
+
+		firstSeen := time.Unix(int64(binary.BigEndian.Uint64(value[:8])), 0) //nolint:gosec
+		lastSeen := time.Unix(int64(binary.BigEndian.Uint64(value[8:])), 0)  //nolint:gosec
+
+		for _, window := range storeWindows {
+			if now.Sub(lastSeen) <= window.duration {
+				summary.unique[window.name]++
+			}
+
+			if now.Sub(firstSeen) <= window.duration {
+				summary.new[window.name]++
+			}
+		}
+
+		return nil
+	})
+
+	return summary
+}
+
+func createStoreDesc(namespace string, stat string, labels prometheus.Labels) *prometheus.Desc {
+	switch stat {
+	case "unique":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "players", "unique"),
+			"The number of distinct players seen within the window",
+			nil, labels)
+	case "new":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "players", "new"),
+			"The number of players seen for the first time within the window",
+			nil, labels)
+	case "first_seen":
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "players", "first_seen_total"),
+			"The total number of players seen for the first time, or after being forgotten once the largest window passed",
+			nil, labels)
+	default:
+		slog.Warn("Unhandled stat Name", slog.String("stat", stat))
+	}
+
+	return nil
+}
+
+// Update computes the counts from the sightings in a read transaction, so scrapes do not block recording.
+func (s *playerStore) Update(_ context.Context, metricCHan chan<- prometheus.Metric) error {
+	s.mu.RLock()
+	config := s.config
+	s.mu.RUnlock()
+
+	now := time.Now()
+
+	return s.db.View(func(tx *bolt.Tx) error {
+		for _, server := range config.Targets {
+			bucket := tx.Bucket([]byte(server.Name))
+			if bucket == nil {
+				continue
+			}
+
+			summary := summarise(bucket, now)
+			labels := config.targetLabels(server)
+
+			for _, window := range storeWindows {
+				windowLabels := mergeLabels(labels, prometheus.Labels{"window": window.name})
+
+				unique := createStoreDesc(config.NameSpace, "unique", windowLabels)
+				newPlayers := createStoreDesc(config.NameSpace, "new", windowLabels)
+
+				metricCHan <- prometheus.MustNewConstMetric(unique, prometheus.GaugeValue, float64(summary.unique[window.name]))
+				metricCHan <- prometheus.MustNewConstMetric(newPlayers, prometheus.GaugeValue, float64(summary.new[window.name]))
+			}
+
+			firstSeen := createStoreDesc(config.NameSpace, "first_seen", labels)
+			metricCHan <- prometheus.MustNewConstMetric(firstSeen, prometheus.CounterValue, float64(summary.total))
+		}
+
+		return nil
+	})
+}
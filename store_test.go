@@ -0,0 +1,89 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leighmacdonald/steamid/v4/steamid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlayerStoreRecord(t *testing.T) {
+	conf := newConfig()
+	conf.StorePath = filepath.Join(t.TempDir(), "players.db")
+	conf.Targets = []Target{{Name: "a", Protocol: protocolRCON}}
+
+	store, errOpen := openPlayerStore(conf)
+	require.NoError(t, errOpen)
+
+	var (
+		alice = steamid.New(76561197960265729)
+		bob   = steamid.New(76561197960265730)
+		now   = time.Now()
+	)
+
+	require.NoError(t, store.record("a", &status{Players: []statusPlayer{
+		{userID: 2, steamID: alice},
+		{userID: 3, name: "Bot01", bot: true},
+	}}, now.Add(-time.Hour*24*3)))
+	require.NoError(t, store.record("a", &status{Players: []statusPlayer{
+		{userID: 4, steamID: bob},
+	}}, now.Add(-time.Hour)))
+	require.NoError(t, store.record("a", &status{}, now))
+
+	text := scrape(t, store)
+	require.Contains(t, text, `srcds_players_unique{server="a",window="24h"} 1`)
+	require.Contains(t, text, `srcds_players_unique{server="a",window="7d"} 2`)
+	require.Contains(t, text, `srcds_players_new{server="a",window="24h"} 1`)
+	require.Contains(t, text, `srcds_players_first_seen_total{server="a"} 2`)
+
+	store.close()
+
+	// Sightings survive a restart, and a returning player keeps their first seen time
+	store, errOpen = openPlayerStore(conf)
+	require.NoError(t, errOpen)
+
+	defer store.close()
+
+	require.NoError(t, store.record("a", &status{Players: []statusPlayer{
+		{userID: 5, steamID: alice},
+	}}, now))
+
+	text = scrape(t, store)
+	require.Contains(t, text, `srcds_players_unique{server="a",window="24h"} 2`)
+	require.Contains(t, text, `srcds_players_new{server="a",window="24h"} 1`)
+	require.Contains(t, text, `srcds_players_new{server="a",window="7d"} 2`)
+	require.Contains(t, text, `srcds_players_first_seen_total{server="a"} 2`)
+}
+
+func TestPlayerStorePrune(t *testing.T) {
+	conf := newConfig()
+	conf.StorePath = filepath.Join(t.TempDir(), "players.db")
+	conf.Targets = []Target{{Name: "a", Protocol: protocolRCON}}
+
+	store, errOpen := openPlayerStore(conf)
+	require.NoError(t, errOpen)
+
+	defer store.close()
+
+	var (
+		alice = steamid.New(76561197960265729)
+		bob   = steamid.New(76561197960265730)
+		now   = time.Now()
+	)
+
+	require.NoError(t, store.record("a", &status{Players: []statusPlayer{{userID: 2, steamID: alice}}}, now.Add(-time.Hour*24*8)))
+	require.NoError(t, store.record("a", &status{Players: []statusPlayer{{userID: 3, steamID: bob}}}, now))
+
+	text := scrape(t, store)
+	require.Contains(t, text, `srcds_players_unique{server="a",window="7d"} 1`)
+	require.Contains(t, text, `srcds_players_first_seen_total{server="a"} 2`)
+
+	// A forgotten player returning is counted as new again.
+	require.NoError(t, store.record("a", &status{Players: []statusPlayer{{userID: 4, steamID: alice}}}, now))
+
+	text = scrape(t, store)
+	require.Contains(t, text, `srcds_players_new{server="a",window="24h"} 2`)
+	require.Contains(t, text, `srcds_players_first_seen_total{server="a"} 3`)
+}
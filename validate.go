@@ -0,0 +1,367 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var reLabelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedLabels are used by the exporter itself or attached by prometheus when scraping.
+var reservedLabels = []string{ //nolint:gochecknoglobals
+	"server", "steam_id", "class", "map", "name", "version", "event", "weapon",
+	"metamod_version", "sourcemod_version", "address", "local_address", "hostname", "build", "secure", "server_steam_id", "account", "tag", "section", "state", "userid", "ip", "port", "window", "reason",
+	"job", "instance",
+}
+
+// reservedMetricPrefixes are the prefixes, after the name_space, of the metrics exported by the exporter
+// itself. Command metrics may not use them, so they can never collide with a built in metric.
+var reservedMetricPrefixes = []string{ //nolint:gochecknoglobals
+	"stats_", "status_", "a2s_", "sourcetv_", "server_", "players_", "player_", "parse_", "map_", "watch_",
+	"scrape_error", "events_total", "log_unmatched_packets_total",
+}
+
+var reHostname = regexp.MustCompile(`^([a-zA-Z0-9_]([a-zA-Z0-9_-]{0,61}[a-zA-Z0-9])?)(\.[a-zA-Z0-9_]([a-zA-Z0-9_-]{0,61}[a-zA-Z0-9])?)*\.?$`)
+
+// configError is a single validation failure. Line is 0 when the value did not come from the config file.
+type configError struct {
+	line    int
+	field   string
+	message string
+}
+
+func (e configError) Error() string {
+	if e.line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", e.line, e.field, e.message)
+	}
+
+	return fmt.Sprintf("%s: %s", e.field, e.message)
+}
+
+// configErrors holds every validation failure found in a config.
+type configErrors []configError
+
+func (e configErrors) Error() string {
+	messages := make([]string, len(e))
+	for idx, err := range e {
+		messages[idx] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// configLines maps dotted field paths, eg: targets.1.name, to the line they were defined on.
+type configLines map[string]int
+
+func newConfigLines(node *yaml.Node) configLines {
+	lines := configLines{}
+	lines.walk("", node)
+
+	return lines
+}
+
+func (l configLines) walk(path string, node *yaml.Node) {
+	if node == nil {
+		return
+	}
+
+	if path != "" {
+		l[path] = node.Line
+	}
+
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+
+		return path + "." + key
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			l.walk(path, child)
+		}
+	case yaml.MappingNode:
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			key := node.Content[idx]
+			l.walk(join(key.Value), node.Content[idx+1])
+			// Point at the key rather than the value, so missing or empty values still get a useful line.
+			l[join(key.Value)] = key.Line
+		}
+	case yaml.SequenceNode:
+		for idx, child := range node.Content {
+			l.walk(join(strconv.Itoa(idx)), child)
+		}
+	case yaml.ScalarNode, yaml.AliasNode:
+	}
+}
+
+// line returns the line of the most specific path that exists.
+func (l configLines) line(paths ...string) int {
+	for _, path := range paths {
+		if line, found := l[path]; found {
+			return line
+		}
+	}
+
+	return 0
+}
+
+// validate checks the config for values that would produce broken or colliding series. It must be called
+// before secrets are loaded so that the password options can be checked.
+func (c *config) validate(lines configLines) error {
+	var errs configErrors
+
+	fail := func(line int, field string, format string, args ...any) {
+		errs = append(errs, configError{line: line, field: field, message: fmt.Sprintf(format, args...)})
+	}
+
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		fail(lines.line("log_level"), "log_level", "must be one of debug, info, warn or error")
+	}
+
+	for _, path := range []struct {
+		field string
+		value string
+	}{{"metrics_path", c.MetricsPath}, {"probe_path", c.ProbePath}} {
+		if !strings.HasPrefix(path.value, "/") {
+			fail(lines.line(path.field), path.field, "must start with /")
+		}
+	}
+
+	if c.MetricsPath == c.ProbePath {
+		fail(lines.line("probe_path"), "probe_path", "must differ from metrics_path")
+	}
+
+	if c.LogListenAddr != "" {
+		if _, _, errSplit := net.SplitHostPort(c.LogListenAddr); errSplit != nil {
+			fail(lines.line("log_listen_addr"), "log_listen_addr", "must be in host:port form")
+		}
+	}
+
+	validateLabels := func(labels map[string]string, prefix string, field string) {
+		for _, labelName := range slices.Sorted(maps.Keys(labels)) {
+			path := prefix + "." + labelName
+
+			switch {
+			case !reLabelName.MatchString(labelName) || strings.HasPrefix(labelName, "__"):
+				fail(lines.line(path, prefix), field+"."+labelName, "invalid label name")
+			case slices.Contains(reservedLabels, labelName):
+				fail(lines.line(path, prefix), field+"."+labelName, "reserved label name")
+			}
+		}
+	}
+
+	validateLabels(c.Labels, "labels", "labels")
+
+	for idx, label := range c.Players.Labels {
+		if !slices.Contains(playerLabels, label) {
+			fail(lines.line(fmt.Sprintf("players.labels.%d", idx), "players.labels"), fmt.Sprintf("players.labels[%d]", idx),
+				"unknown player label %q, must be one of %s", label, strings.Join(playerLabels, ", "))
+		}
+	}
+
+	switch c.Players.IPMode {
+	case ipModeHash, ipModeTruncate, ipModeRaw:
+	default:
+		fail(lines.line("players.ip_mode"), "players.ip_mode", "must be one of hash, truncate or raw")
+	}
+
+	// An unkeyed hash of the small ipv4 space is reversed by hashing every address.
+	if c.Players.IPMode == ipModeHash && c.Players.HashSalt == "" && slices.Contains(c.Players.Labels, playerLabelIP) {
+		fail(lines.line("players.hash_salt", "players"), "players.hash_salt", "is required when exporting hashed ips")
+	}
+
+	names := map[string]int{}
+	secrets := map[string]int{}
+
+	for idx, target := range c.Targets {
+		prefix := "targets." + strconv.Itoa(idx)
+		field := func(name string) (int, string) {
+			return lines.line(prefix+"."+name, prefix), fmt.Sprintf("targets[%d].%s", idx, name)
+		}
+
+		if target.Name == "" {
+			line, name := field("name")
+			fail(line, name, "is required")
+		} else if first, found := names[target.Name]; found {
+			line, name := field("name")
+			fail(line, name, "duplicate target name %q, first used by targets[%d]", target.Name, first)
+		} else {
+			names[target.Name] = idx
+		}
+
+		if target.Host == "" {
+			line, name := field("host")
+			fail(line, name, "is required")
+		} else if net.ParseIP(target.Host) == nil && !reHostname.MatchString(target.Host) {
+			line, name := field("host")
+			fail(line, name, "%q is not a valid hostname or ip address", target.Host)
+		}
+
+		if target.Port == 0 {
+			line, name := field("port")
+			fail(line, name, "is required")
+		}
+
+		switch target.Protocol {
+		case protocolRCON:
+			if target.Password == "" && target.PasswordFile == "" && target.PasswordEnv == "" {
+				line, name := field("password")
+				fail(line, name, "one of password, password_file or password_env is required for the rcon protocol")
+			}
+		case protocolA2S:
+		default:
+			line, name := field("protocol")
+			fail(line, name, "unknown protocol %q", target.Protocol)
+		}
+
+		if !slices.Contains(games, target.Game) {
+			line, name := field("game")
+			fail(line, name, "unknown game %q, must be one of %s", target.Game, strings.Join(games, ", "))
+		}
+
+		validateLabels(target.Labels, prefix+".labels", fmt.Sprintf("targets[%d].labels", idx))
+
+		if target.LogSecret != "" {
+			if first, found := secrets[target.LogSecret]; found {
+				line, name := field("log_secret")
+				fail(line, name, "duplicate log secret, first used by targets[%d]", first)
+			} else {
+				secrets[target.LogSecret] = idx
+			}
+		}
+	}
+
+	metrics := map[string]int{}
+
+	for idx, command := range c.Commands {
+		prefix := "commands." + strconv.Itoa(idx)
+		field := func(name string) (int, string) {
+			return lines.line(prefix+"."+name, prefix), fmt.Sprintf("commands[%d].%s", idx, name)
+		}
+
+		if command.Command == "" {
+			line, name := field("command")
+			fail(line, name, "is required")
+		}
+
+		reservedPrefix := slices.IndexFunc(reservedMetricPrefixes, func(prefix string) bool {
+			return strings.HasPrefix(command.Metric, prefix)
+		})
+
+		if !reMetricName.MatchString(command.Metric) {
+			line, name := field("metric")
+			fail(line, name, "invalid metric name %q", command.Metric)
+		} else if reservedPrefix >= 0 {
+			line, name := field("metric")
+			fail(line, name, "metric name %q collides with the built in %s metrics", command.Metric,
+				reservedMetricPrefixes[reservedPrefix])
+		} else if first, found := metrics[command.Metric]; found {
+			line, name := field("metric")
+			fail(line, name, "duplicate metric name %q, first used by commands[%d]", command.Metric, first)
+		} else {
+			metrics[command.Metric] = idx
+		}
+
+		switch command.Type {
+		case commandTypeGauge, commandTypeCounter:
+		default:
+			line, name := field("type")
+			fail(line, name, "must be one of gauge or counter")
+		}
+
+		for _, target := range command.Targets {
+			if _, found := names[target]; !found {
+				line, name := field("targets")
+				fail(line, name, "unknown target %q", target)
+			}
+		}
+
+		regex, errRegex := regexp.Compile(command.Regex)
+		if errRegex != nil || command.Regex == "" {
+			line, name := field("regex")
+			fail(line, name, "must be a valid regular expression")
+
+			continue
+		}
+
+		if command.Value != "" && !slices.Contains(regex.SubexpNames(), command.Value) {
+			line, name := field("value")
+			fail(line, name, "regex has no group named %q", command.Value)
+		}
+
+		for labelIdx, label := range command.Labels {
+			line := lines.line(fmt.Sprintf("%s.labels.%d", prefix, labelIdx), prefix+".labels", prefix)
+			name := fmt.Sprintf("commands[%d].labels[%d]", idx, labelIdx)
+
+			switch {
+			case !slices.Contains(regex.SubexpNames(), label):
+				fail(line, name, "regex has no group named %q", label)
+			case label == command.Value:
+				fail(line, name, "group %q is already used as the value", label)
+			case !reLabelName.MatchString(label) || strings.HasPrefix(label, "__"):
+				fail(line, name, "invalid label name")
+			case slices.Contains(reservedLabels, label):
+				fail(line, name, "reserved label name")
+			default:
+				if _, found := c.Labels[label]; found {
+					fail(line, name, "label %q is already set by labels", label)
+
+					continue
+				}
+
+				for targetIdx, target := range c.Targets {
+					if _, found := target.Labels[label]; found && command.appliesTo(target) {
+						fail(line, name, "label %q is already set by targets[%d].labels", label, targetIdx)
+
+						break
+					}
+				}
+			}
+		}
+	}
+
+	for _, moduleName := range slices.Sorted(maps.Keys(c.Modules)) {
+		module := c.Modules[moduleName]
+		prefix := "modules." + moduleName
+
+		switch module.Protocol {
+		case protocolRCON, protocolA2S:
+		default:
+			fail(lines.line(prefix+".protocol", prefix), prefix+".protocol", "unknown protocol %q", module.Protocol)
+		}
+
+		if !slices.Contains(games, module.Game) {
+			fail(lines.line(prefix+".game", prefix), prefix+".game", "unknown game %q, must be one of %s", module.Game, strings.Join(games, ", "))
+		}
+
+		if module.Protocol == protocolRCON && len(module.AllowedTargets) == 0 {
+			fail(lines.line(prefix+".allowed_targets", prefix), prefix+".allowed_targets", "is required for the rcon protocol")
+		}
+
+		for idx, allowed := range module.AllowedTargets {
+			_, _, errCIDR := net.ParseCIDR(allowed)
+			if errCIDR != nil && net.ParseIP(allowed) == nil && !reHostname.MatchString(allowed) {
+				fail(lines.line(fmt.Sprintf("%s.allowed_targets.%d", prefix, idx), prefix+".allowed_targets"),
+					fmt.Sprintf("%s.allowed_targets[%d]", prefix, idx), "%q is not a valid hostname, ip address or cidr", allowed)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}